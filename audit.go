@@ -0,0 +1,99 @@
+package sling
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// AuditRecord describes one request sent through an AuditDoer. Bodies and
+// URL userinfo (e.g. passwords) are never recorded.
+type AuditRecord struct {
+	// Time the request was sent
+	Time time.Time
+	// Caller who made the request, set with WithAuditCaller, or empty
+	Caller string
+	Method string
+	URL    string
+	// StatusCode of the response, or 0 if no response was received
+	StatusCode int
+	Duration   time.Duration
+	// RequestBytes and ResponseBytes are the Body ContentLengths, -1 if unknown
+	RequestBytes  int64
+	ResponseBytes int64
+	// Err is any error returned by the wrapped Doer
+	Err error
+}
+
+// AuditSink receives AuditRecords, e.g. to write them to a file or queue.
+// Audit is called synchronously after each request so implementations
+// should be fast and safe for concurrent use.
+type AuditSink interface {
+	Audit(record AuditRecord)
+}
+
+// AuditSinkFunc adapts an ordinary function to the AuditSink interface.
+type AuditSinkFunc func(record AuditRecord)
+
+// Audit calls f(record).
+func (f AuditSinkFunc) Audit(record AuditRecord) {
+	f(record)
+}
+
+// auditCallerKey is the Request context key of the caller recorded by an
+// AuditDoer.
+type auditCallerKey struct{}
+
+// WithAuditCaller returns a copy of ctx which identifies the caller, e.g. a
+// user or service name, to be recorded by an AuditDoer for requests made
+// with the context (see Context()).
+func WithAuditCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, auditCallerKey{}, caller)
+}
+
+// auditDoer is a Doer which sends an AuditRecord for each request.
+type auditDoer struct {
+	next Doer
+	sink AuditSink
+	now  func() time.Time
+}
+
+// AuditDoer returns a Doer which does requests with next and emits an
+// AuditRecord for each one to the sink. If next is nil, the
+// http.DefaultClient will be used.
+func AuditDoer(next Doer, sink AuditSink) Doer {
+	if next == nil {
+		next = http.DefaultClient
+	}
+	return &auditDoer{next: next, sink: sink, now: time.Now}
+}
+
+func (d *auditDoer) Do(req *http.Request) (*http.Response, error) {
+	start := d.now()
+	resp, err := d.next.Do(req)
+	caller, _ := req.Context().Value(auditCallerKey{}).(string)
+	// omit any credentials in the URL
+	u := *req.URL
+	u.User = nil
+	record := AuditRecord{
+		Time:          start,
+		Caller:        caller,
+		Method:        req.Method,
+		URL:           u.String(),
+		Duration:      d.now().Sub(start),
+		RequestBytes:  req.ContentLength,
+		ResponseBytes: -1,
+		Err:           err,
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		record.RequestBytes = 0
+	} else if req.ContentLength == 0 {
+		record.RequestBytes = -1
+	}
+	if resp != nil {
+		record.StatusCode = resp.StatusCode
+		record.ResponseBytes = resp.ContentLength
+	}
+	d.sink.Audit(record)
+	return resp, err
+}
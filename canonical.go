@@ -0,0 +1,68 @@
+package sling
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// CanonicalQuery formats query values as a canonical query string, for
+// providers which verify signatures over the query. Keys are sorted, the
+// values of each key are sorted, and keys and values are percent-encoded
+// with escape. If escape is nil, RFC 3986 encoding is used (spaces are
+// encoded as "%20" and only unreserved characters are left unencoded).
+func CanonicalQuery(query url.Values, escape func(string) string) string {
+	if escape == nil {
+		escape = escapeRFC3986
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, escape(key)+"="+escape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// CanonicalizeQuery rewrites the query of each new request with
+// CanonicalQuery, after query structs have been encoded (see Interceptor).
+// Interceptors added afterwards, such as one computing a signature, see the
+// canonical query.
+func (s *Sling) CanonicalizeQuery(escape func(string) string) *Sling {
+	return s.Interceptor(func(req *http.Request) error {
+		query, err := url.ParseQuery(req.URL.RawQuery)
+		if err != nil {
+			return err
+		}
+		req.URL.RawQuery = CanonicalQuery(query, escape)
+		return nil
+	})
+}
+
+// escapeRFC3986 percent-encodes all bytes of s except the RFC 3986
+// unreserved characters.
+func escapeRFC3986(s string) string {
+	const hex = "0123456789ABCDEF"
+	buf := new(bytes.Buffer)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			buf.WriteByte(c)
+			continue
+		}
+		buf.WriteByte('%')
+		buf.WriteByte(hex[c>>4])
+		buf.WriteByte(hex[c&15])
+	}
+	return buf.String()
+}
@@ -0,0 +1,47 @@
+package sling
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestCanonicalQuery(t *testing.T) {
+	cases := []struct {
+		query    url.Values
+		escape   func(string) string
+		expected string
+	}{
+		{url.Values{}, nil, ""},
+		{url.Values{"b": []string{"2"}, "a": []string{"3", "1"}}, nil, "a=1&a=3&b=2"},
+		{url.Values{"q": []string{"a b+c/d~"}}, nil, "q=a%20b%2Bc%2Fd~"},
+		{url.Values{"q": []string{"a b"}}, url.QueryEscape, "q=a+b"},
+		{url.Values{"k y": []string{"ü"}}, nil, "k%20y=%C3%BC"},
+	}
+	for _, c := range cases {
+		if actual := CanonicalQuery(c.query, c.escape); actual != c.expected {
+			t.Errorf("expected %s, got %s", c.expected, actual)
+		}
+	}
+}
+
+func TestCanonicalizeQuery(t *testing.T) {
+	var signed string
+	sling := New().Base("http://a.io/?z=last&b=2&b=1").QueryStruct(FakeParams{KindName: "a b", Count: 3}).
+		CanonicalizeQuery(nil).
+		Interceptor(func(req *http.Request) error {
+			signed = req.URL.RawQuery
+			return nil
+		})
+	req, err := sling.Request()
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	expected := "b=1&b=2&count=3&kind_name=a%20b&z=last"
+	if req.URL.RawQuery != expected {
+		t.Errorf("expected %s, got %s", expected, req.URL.RawQuery)
+	}
+	if signed != expected {
+		t.Errorf("later interceptors should see canonical query %s, got %s", expected, signed)
+	}
+}
@@ -0,0 +1,83 @@
+package sling
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// maxBodyPreview is the number of body bytes included in a
+// RequestDescription.
+const maxBodyPreview = 1024
+
+// redactedHeaders are the Header keys whose values are hidden in a
+// RequestDescription.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// RequestDescription describes the request a Sling would send, without
+// sending it. It is intended for debug output and dry runs.
+type RequestDescription struct {
+	Method string
+	URL    string
+	// Header is a copy of the request Header with credential values redacted
+	Header      http.Header
+	ContentType string
+	// BodyPreview holds up to the first 1024 bytes of the body, if the body
+	// can be read without consuming it
+	BodyPreview string
+}
+
+// Describe returns a RequestDescription of the request created with the
+// Sling properties. Returns any errors creating the request (see Request()).
+// The Sling's body is not consumed, so the preview is omitted for plain Body
+// readers which can't be re-read.
+//
+// Describe creates a real Request, so it has the same side effects: a
+// BodyProvider is called once, interceptors run (e.g. an IdempotencyKey is
+// generated), and HeaderTemplate data funcs are called. The preview replays
+// the body the Request encoded rather than providing a new one.
+func (s *Sling) Describe() (*RequestDescription, error) {
+	req, err := s.Request()
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	for key, values := range req.Header {
+		header[key] = append([]string{}, values...)
+	}
+	for _, key := range redactedHeaders {
+		if header.Get(key) != "" {
+			header.Set(key, "REDACTED")
+		}
+	}
+	preview, err := bodyPreview(req)
+	if err != nil {
+		return nil, err
+	}
+	return &RequestDescription{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		Header:      header,
+		ContentType: req.Header.Get(contentType),
+		BodyPreview: preview,
+	}, nil
+}
+
+// bodyPreview reads the start of the request body from a fresh copy
+// obtained with req.GetBody. An empty string is returned if the body can't be
+// copied.
+func bodyPreview(req *http.Request) (string, error) {
+	if req.GetBody == nil {
+		return "", nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	preview, err := ioutil.ReadAll(io.LimitReader(body, maxBodyPreview))
+	if err != nil {
+		return "", err
+	}
+	return string(preview), nil
+}
@@ -0,0 +1,103 @@
+package sling
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	sling := New().Base("http://a.io/").Post("foo").QueryStruct(paramsA).
+		SetBasicAuth("user", "pass").Set("User-Agent", "sling").BodyJSON(modelA)
+	desc, err := sling.Describe()
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	expected := &RequestDescription{
+		Method: "POST",
+		URL:    "http://a.io/foo?limit=30",
+		Header: http.Header{
+			"Authorization": []string{"REDACTED"},
+			"User-Agent":    []string{"sling"},
+			"Content-Type":  []string{jsonContentType},
+		},
+		ContentType: jsonContentType,
+		BodyPreview: "{\"text\":\"note\",\"favorite_count\":12}\n",
+	}
+	if !reflect.DeepEqual(expected, desc) {
+		t.Errorf("not DeepEqual: expected %+v, got %+v", expected, desc)
+	}
+	// the Sling's own header should not be redacted
+	if sling.header.Get("Authorization") == "REDACTED" {
+		t.Errorf("Describe should not modify the Sling header")
+	}
+}
+
+func TestDescribe_bodyPreview(t *testing.T) {
+	long := strings.Repeat("a", maxBodyPreview+10)
+	cases := []struct {
+		sling           *Sling
+		expectedPreview string
+	}{
+		{New(), ""},
+		{New().BodyForm(paramsB), "count=25&kind_name=recent"},
+		{New().Body(strings.NewReader("raw")), "raw"},
+		{New().Body(strings.NewReader(long)), long[:maxBodyPreview]},
+		// readers which can't be re-read are not previewed
+		{New().Body(struct{ *bytes.Reader }{bytes.NewReader([]byte("raw"))}), ""},
+	}
+	for _, c := range cases {
+		desc, err := c.sling.Describe()
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+			continue
+		}
+		if desc.BodyPreview != c.expectedPreview {
+			t.Errorf("expected preview %q, got %q", c.expectedPreview, desc.BodyPreview)
+		}
+	}
+}
+
+func TestDescribe_doesNotConsumeBody(t *testing.T) {
+	sling := New().Body(strings.NewReader("raw"))
+	if _, err := sling.Describe(); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	req, _ := sling.Request()
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(req.Body)
+	if buf.String() != "raw" {
+		t.Errorf("expected body %q, got %q", "raw", buf.String())
+	}
+}
+
+func TestDescribe_bodyProviderCalledOnce(t *testing.T) {
+	calls := 0
+	provider := BodyProvider(func() (interface{}, error) {
+		calls++
+		return modelA, nil
+	})
+	desc, err := New().Post("http://a.io/").BodyJSON(provider).Describe()
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected provider to be called once, got %d", calls)
+	}
+	if expected := "{\"text\":\"note\",\"favorite_count\":12}\n"; desc.BodyPreview != expected {
+		t.Errorf("expected %q, got %q", expected, desc.BodyPreview)
+	}
+}
+
+func TestDescribe_errorCreatingRequest(t *testing.T) {
+	desc, err := New().BodyJSON(FakeModel{Temperature: math.Inf(1)}).Describe()
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if desc != nil {
+		t.Errorf("expected nil RequestDescription, got %+v", desc)
+	}
+}
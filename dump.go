@@ -0,0 +1,53 @@
+package sling
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+)
+
+// dumpDoer is a Doer which writes requests and responses to a Writer.
+type dumpDoer struct {
+	next Doer
+	body bool
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+// DumpDoer returns a Doer which does requests with next and writes each
+// outgoing request and incoming response to w in wire format (see
+// httputil.DumpRequestOut and httputil.DumpResponse), for debugging. Bodies
+// are included if body is true, which requires reading them into memory.
+// If next is nil, the http.DefaultClient will be used.
+func DumpDoer(next Doer, w io.Writer, body bool) Doer {
+	if next == nil {
+		next = http.DefaultClient
+	}
+	return &dumpDoer{next: next, body: body, w: w}
+}
+
+func (d *dumpDoer) Do(req *http.Request) (*http.Response, error) {
+	d.dump(httputil.DumpRequestOut(req, d.body))
+	resp, err := d.next.Do(req)
+	if err != nil {
+		d.dump(nil, err)
+	}
+	if resp != nil {
+		d.dump(httputil.DumpResponse(resp, d.body))
+	}
+	return resp, err
+}
+
+// dump writes a dumped request or response, or the error dumping it.
+func (d *dumpDoer) dump(dump []byte, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		fmt.Fprintf(d.w, "%v\n\n", err)
+		return
+	}
+	d.w.Write(dump)
+	fmt.Fprint(d.w, "\n\n")
+}
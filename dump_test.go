@@ -0,0 +1,67 @@
+package sling
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDumpDoer(t *testing.T) {
+	client, mux, server := testServer()
+	defer server.Close()
+	mux.HandleFunc("/submit", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"text": %q}`, body)
+	})
+	cases := []struct {
+		body       bool
+		contains   []string
+		notContain []string
+	}{
+		{true, []string{"POST /submit HTTP/1.1", "limit=30", "HTTP/1.1 200 OK", `{"text": "limit=30"}`}, nil},
+		{false, []string{"POST /submit HTTP/1.1", "HTTP/1.1 200 OK"}, []string{"limit=30"}},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		model := new(FakeModel)
+		_, err := New().Doer(DumpDoer(client, out, c.body)).Post("http://example.com/submit").BodyForm(paramsA).Receive(model, nil)
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+		}
+		// dumping should not consume the request or response bodies
+		if model.Text != "limit=30" {
+			t.Errorf("expected %s, got %s", "limit=30", model.Text)
+		}
+		for _, s := range c.contains {
+			if !strings.Contains(out.String(), s) {
+				t.Errorf("expected dump to contain %q, got %q", s, out.String())
+			}
+		}
+		for _, s := range c.notContain {
+			if strings.Contains(out.String(), s) {
+				t.Errorf("expected dump not to contain %q, got %q", s, out.String())
+			}
+		}
+	}
+}
+
+func TestDumpDoer_error(t *testing.T) {
+	out := new(bytes.Buffer)
+	expectedErr := errors.New("doer failure")
+	next := doerFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, expectedErr
+	})
+	req, _ := New().Get("http://example.com/").Request()
+	_, err := DumpDoer(next, out, true).Do(req)
+	if err != expectedErr {
+		t.Errorf("expected %v, got %v", expectedErr, err)
+	}
+	if !strings.Contains(out.String(), "doer failure") {
+		t.Errorf("expected dump to contain the error, got %q", out.String())
+	}
+}
@@ -0,0 +1,200 @@
+package sling
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// hedgeDoer is a Doer which sends a duplicate request if the first is slow.
+type hedgeDoer struct {
+	next  Doer
+	delay time.Duration
+}
+
+// HedgeDoer returns a Doer which does requests with next and, if no
+// response has arrived after delay, sends a duplicate request. The first
+// successful (2XX) response wins and the other attempt is canceled via its
+// context. If neither attempt succeeds, the original attempt's result is
+// returned, unless it failed with an error and the duplicate got a response.
+// A failure which arrives before the delay is returned without hedging.
+//
+// Since duplicates may both reach the server, only idempotent requests (GET,
+// HEAD, OPTIONS, PUT, and DELETE) and requests with an Idempotency-Key
+// header are hedged. Requests with a Body are only hedged if the Body can
+// be replayed with GetBody (see Request()). Other requests are done with
+// next as is. If next is nil, the http.DefaultClient will be used.
+func HedgeDoer(next Doer, delay time.Duration) Doer {
+	if next == nil {
+		next = http.DefaultClient
+	}
+	return &hedgeDoer{next: next, delay: delay}
+}
+
+// hedgeResult is the outcome of one attempt.
+type hedgeResult struct {
+	attempt int
+	resp    *http.Response
+	err     error
+}
+
+func (d *hedgeDoer) Do(req *http.Request) (*http.Response, error) {
+	if !hedgeable(req) {
+		return d.next.Do(req)
+	}
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	attempt := func(attemptReq *http.Request) {
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels = append(cancels, cancel)
+		go func(attempt int) {
+			resp, err := d.next.Do(attemptReq.WithContext(ctx))
+			results <- hedgeResult{attempt, resp, err}
+		}(len(cancels) - 1)
+	}
+	attempt(req)
+	pending := 1
+	// the result to return if no attempt succeeds
+	var fallback *hedgeResult
+	timer := time.NewTimer(d.delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			hedgeReq, err := copyRequest(req)
+			if err != nil {
+				continue
+			}
+			attempt(hedgeReq)
+			pending++
+		case result := <-results:
+			pending--
+			if result.err == nil && isSuccessStatus(result.resp.StatusCode) {
+				// cancel the loser, and release the winner's context along
+				// with its response
+				for i, cancel := range cancels {
+					if i != result.attempt {
+						cancel()
+					}
+				}
+				if fallback != nil {
+					releaseResult(*fallback, cancels[fallback.attempt])
+				}
+				go discardResults(results, pending)
+				return withCancelBody(result, cancels[result.attempt])
+			}
+			if preferResult(result, fallback) {
+				if fallback != nil {
+					releaseResult(*fallback, cancels[fallback.attempt])
+				}
+				fallback = &result
+			} else {
+				releaseResult(result, cancels[result.attempt])
+			}
+			// hedging is not a retry, so a failed first attempt is returned
+			// unless the duplicate is still pending
+			if pending == 0 {
+				if fallback.err != nil {
+					cancels[fallback.attempt]()
+					return fallback.resp, fallback.err
+				}
+				return withCancelBody(*fallback, cancels[fallback.attempt])
+			}
+		}
+	}
+}
+
+// hedgeable returns true if req is idempotent, or has an Idempotency-Key,
+// and its Body, if any, can be replayed.
+func hedgeable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case "", "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// preferResult returns true if the unsuccessful result should be returned
+// instead of fallback, regardless of which arrived first. Responses are
+// preferred over errors, then the original attempt over the duplicate.
+func preferResult(result hedgeResult, fallback *hedgeResult) bool {
+	if fallback == nil {
+		return true
+	}
+	if (result.err == nil) != (fallback.err == nil) {
+		return result.err == nil
+	}
+	return result.attempt < fallback.attempt
+}
+
+// isSuccessStatus returns true if code is a 2XX status code.
+func isSuccessStatus(code int) bool {
+	return 200 <= code && code <= 299
+}
+
+// withCancelBody returns the result's response with a Body which releases
+// the attempt's context once closed.
+func withCancelBody(result hedgeResult, cancel context.CancelFunc) (*http.Response, error) {
+	result.resp.Body = &cancelBody{result.resp.Body, cancel}
+	return result.resp, nil
+}
+
+// releaseResult cancels an attempt which won't be returned and closes its
+// response Body, if any.
+func releaseResult(result hedgeResult, cancel context.CancelFunc) {
+	cancel()
+	if result.resp != nil {
+		result.resp.Body.Close()
+	}
+}
+
+// copyRequest returns a copy of req with a fresh Body from GetBody, or
+// computed again by the BodyProvider the request was created with (see
+// Request()). The ContentLength is updated if a known length is replaced by
+// a provided Body which reports a different length.
+func copyRequest(req *http.Request) (*http.Request, error) {
+	reqCopy := req.WithContext(req.Context())
+	getBody := req.GetBody
+	if provide, ok := req.Context().Value(bodyProviderKey{}).(func() (io.ReadCloser, error)); ok && getBody != nil {
+		getBody = provide
+	}
+	if getBody != nil {
+		body, err := getBody()
+		if err != nil {
+			return nil, err
+		}
+		reqCopy.Body = body
+		if provided, ok := body.(*providedBody); ok && req.ContentLength > 0 && provided.Len() >= 0 {
+			reqCopy.ContentLength = int64(provided.Len())
+		}
+	}
+	return reqCopy, nil
+}
+
+// discardResults receives n losing attempts and closes their response
+// Bodies.
+func discardResults(results <-chan hedgeResult, n int) {
+	for i := 0; i < n; i++ {
+		result := <-results
+		if result.resp != nil {
+			DrainAndClose(result.resp)
+		}
+	}
+}
+
+// cancelBody is a response Body which cancels its request context once
+// closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,194 @@
+package sling
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hedgeAttempt is the result of one attempt of a hedged request.
+type hedgeAttempt struct {
+	status int
+	err    error
+}
+
+func (a hedgeAttempt) response() (*http.Response, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	return testResponse(a.status, nil), nil
+}
+
+func TestHedgeDoer(t *testing.T) {
+	cases := []*Sling{
+		New().Put("http://example.com/").BodyForm(paramsA),
+		// non-idempotent requests are hedged with an Idempotency-Key
+		New().Post("http://example.com/").BodyForm(paramsA).Set("Idempotency-Key", "abc"),
+	}
+	for _, sling := range cases {
+		// the original attempt hangs until it is canceled
+		rec := &stubDoer{respond: func(attempt int, req *http.Request) (*http.Response, error) {
+			if attempt == 0 {
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			}
+			return testResponse(201, nil), nil
+		}}
+		req, _ := sling.Request()
+		resp, err := HedgeDoer(rec, time.Millisecond).Do(req)
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+		// the duplicate request wins
+		if resp.StatusCode != 201 {
+			t.Errorf("expected %d, got %d", 201, resp.StatusCode)
+		}
+		resp.Body.Close()
+		if expected := []string{"limit=30", "limit=30"}; !reflect.DeepEqual(expected, rec.bodies) {
+			t.Errorf("expected body to be replayed, got %v", rec.bodies)
+		}
+		if rec.requests[0].Context().Err() == nil {
+			t.Errorf("expected losing attempt to be canceled")
+		}
+	}
+}
+
+func TestHedgeDoer_nonIdempotent(t *testing.T) {
+	cases := []*Sling{
+		New().Post("http://example.com/").BodyForm(paramsA),
+		New().Patch("http://example.com/").BodyJSON(modelA),
+		New().Post("http://example.com/"),
+	}
+	for _, sling := range cases {
+		// respond after a duplicate would have been sent
+		rec := &stubDoer{respond: func(attempt int, req *http.Request) (*http.Response, error) {
+			time.Sleep(20 * time.Millisecond)
+			return testResponse(200, nil), nil
+		}}
+		req, _ := sling.Request()
+		resp, err := HedgeDoer(rec, time.Millisecond).Do(req)
+		if err != nil || resp.StatusCode != 200 {
+			t.Fatalf("expected 200 response, got %v, %v", resp, err)
+		}
+		resp.Body.Close()
+		if rec.count() != 1 {
+			t.Errorf("expected 1 attempt for %s, got %d", req.Method, rec.count())
+		}
+	}
+}
+
+func TestHedgeDoer_fastResponse(t *testing.T) {
+	failure := errors.New("failure")
+	cases := []hedgeAttempt{{status: 200}, {status: 503}, {err: failure}}
+	for _, c := range cases {
+		c := c
+		rec := &stubDoer{respond: func(attempt int, req *http.Request) (*http.Response, error) {
+			return c.response()
+		}}
+		req, _ := New().Get("http://example.com/").Request()
+		resp, err := HedgeDoer(rec, time.Second).Do(req)
+		// results before the delay are returned without hedging
+		if err != c.err {
+			t.Errorf("expected %v, got %v", c.err, err)
+		}
+		if c.err == nil {
+			if resp.StatusCode != c.status {
+				t.Errorf("expected %d, got %d", c.status, resp.StatusCode)
+			}
+			resp.Body.Close()
+		}
+		if rec.count() != 1 {
+			t.Errorf("expected 1 attempt, got %d", rec.count())
+		}
+	}
+}
+
+func TestHedgeDoer_unsuccessful(t *testing.T) {
+	firstErr := errors.New("first failure")
+	secondErr := errors.New("second failure")
+	cases := []struct {
+		attempts       []hedgeAttempt
+		expectedStatus int
+		expectedErr    error
+	}{
+		// a failure loses to a success
+		{[]hedgeAttempt{{status: 503}, {status: 200}}, 200, nil},
+		{[]hedgeAttempt{{err: firstErr}, {status: 201}}, 201, nil},
+		{[]hedgeAttempt{{status: 200}, {err: secondErr}}, 200, nil},
+		// the original response is returned if neither succeeds
+		{[]hedgeAttempt{{status: 503}, {status: 500}}, 503, nil},
+		// a response is returned over an error
+		{[]hedgeAttempt{{status: 503}, {err: secondErr}}, 503, nil},
+		{[]hedgeAttempt{{err: firstErr}, {status: 500}}, 500, nil},
+		// the original error is returned if both attempts fail
+		{[]hedgeAttempt{{err: firstErr}, {err: secondErr}}, 0, firstErr},
+	}
+	for _, c := range cases {
+		attempts := c.attempts
+		rec := &stubDoer{}
+		// the original attempt completes only once the duplicate is sent, so
+		// the result doesn't depend on which completes first
+		rec.respond = func(attempt int, req *http.Request) (*http.Response, error) {
+			if attempt == 0 {
+				if err := rec.wait(req.Context(), 2); err != nil {
+					return nil, err
+				}
+			}
+			return attempts[attempt].response()
+		}
+		req, _ := New().Get("http://example.com/").Request()
+		resp, err := HedgeDoer(rec, time.Millisecond).Do(req)
+		if err != c.expectedErr {
+			t.Errorf("expected %v, got %v", c.expectedErr, err)
+		}
+		if c.expectedStatus != 0 {
+			if resp == nil || resp.StatusCode != c.expectedStatus {
+				t.Errorf("expected %d, got %v", c.expectedStatus, resp)
+				continue
+			}
+			resp.Body.Close()
+		}
+	}
+}
+
+func TestHedgeDoer_unreplayableBody(t *testing.T) {
+	rec := &stubDoer{respond: func(attempt int, req *http.Request) (*http.Response, error) {
+		time.Sleep(20 * time.Millisecond)
+		return testResponse(200, nil), nil
+	}}
+	body := struct{ *strings.Reader }{strings.NewReader("raw")}
+	req, _ := New().Put("http://example.com/").Body(body).Request()
+	resp, err := HedgeDoer(rec, time.Millisecond).Do(req)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	resp.Body.Close()
+	if rec.count() != 1 || rec.bodies[0] != "raw" {
+		t.Errorf("expected a single attempt with the body, got %v", rec.bodies)
+	}
+}
+
+func TestHedgeDoer_contextCanceled(t *testing.T) {
+	// both attempts hang until they are canceled
+	rec := &stubDoer{respond: func(attempt int, req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		rec.wait(context.Background(), 2)
+		cancel()
+	}()
+	req, _ := New().Get("http://example.com/").Request()
+	_, err := HedgeDoer(rec, time.Millisecond).Do(req.WithContext(ctx))
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if rec.count() != 2 {
+		t.Errorf("expected 2 attempts, got %d", rec.count())
+	}
+}
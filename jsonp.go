@@ -0,0 +1,41 @@
+package sling
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// UnwrapJSONP returns the JSON payload of a JSONP response body, such as
+// `callback({"id": 1});`, so it can be passed to json.Unmarshal. A leading
+// "/**/" comment, which some servers add to guard against content sniffing,
+// is allowed. An error is returned if data isn't a JSONP callback.
+func UnwrapJSONP(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("/**/")))
+	open := bytes.IndexByte(data, '(')
+	if open <= 0 || !isJSONPCallback(bytes.TrimSpace(data[:open])) {
+		return nil, fmt.Errorf("sling: body is not a JSONP callback")
+	}
+	rest := bytes.TrimSpace(bytes.TrimSuffix(data[open+1:], []byte(";")))
+	if !bytes.HasSuffix(rest, []byte(")")) {
+		return nil, fmt.Errorf("sling: JSONP callback is missing a closing ')'")
+	}
+	return bytes.TrimSpace(rest[:len(rest)-1]), nil
+}
+
+// isJSONPCallback returns true if name looks like a JavaScript callback
+// name, allowing dotted names such as "jQuery.cb".
+func isJSONPCallback(name []byte) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '_', c == '$', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package sling
+
+import (
+	"testing"
+)
+
+func TestUnwrapJSONP(t *testing.T) {
+	cases := []struct {
+		data     string
+		expected string
+	}{
+		{`callback({"id": 1})`, `{"id": 1}`},
+		{`callback({"id": 1});`, `{"id": 1}`},
+		{" jQuery_123.cb ( [1, 2] ) ;\n", `[1, 2]`},
+		{`/**/ cb({"text": "(nested)"});`, `{"text": "(nested)"}`},
+		{`$cb("text")`, `"text"`},
+	}
+	for _, c := range cases {
+		payload, err := UnwrapJSONP([]byte(c.data))
+		if err != nil {
+			t.Errorf("expected nil, got %v for %q", err, c.data)
+		}
+		if string(payload) != c.expected {
+			t.Errorf("expected %s, got %s", c.expected, payload)
+		}
+	}
+}
+
+func TestUnwrapJSONP_errors(t *testing.T) {
+	cases := []string{
+		`{"id": 1}`,
+		`({"id": 1})`,
+		`cb({"id": 1}`,
+		``,
+	}
+	for _, data := range cases {
+		if _, err := UnwrapJSONP([]byte(data)); err == nil {
+			t.Errorf("expected an error for %q, got nil", data)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package sling
+
+import (
+	"net/http"
+)
+
+// methodOverrideDoer is a Doer which retries rejected PUT, PATCH, and DELETE
+// requests as POST requests with an X-HTTP-Method-Override header.
+type methodOverrideDoer struct {
+	next Doer
+}
+
+// MethodOverrideDoer returns a Doer which does requests with next and, when
+// a PUT, PATCH, or DELETE request gets a 405 Method Not Allowed or 501 Not
+// Implemented response, retries it once as a POST request with the original
+// method in the X-HTTP-Method-Override header. This works around proxies
+// and gateways which reject uncommon methods, for servers which honor the
+// header. The response is returned as is if a request Body can't be
+// replayed with GetBody (see Request()). If next is nil, the
+// http.DefaultClient will be used.
+func MethodOverrideDoer(next Doer) Doer {
+	if next == nil {
+		next = http.DefaultClient
+	}
+	return &methodOverrideDoer{next: next}
+}
+
+func (d *methodOverrideDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.next.Do(req)
+	if err != nil || !overridableMethod(req.Method) {
+		return resp, err
+	}
+	if resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented {
+		return resp, nil
+	}
+	retryReq, ok := retryRequest(req)
+	if !ok {
+		return resp, nil
+	}
+	DrainAndClose(resp)
+	return d.next.Do(withMethodOverride(retryReq))
+}
+
+// overridableMethod returns true if method may be sent as a POST with an
+// X-HTTP-Method-Override header.
+func overridableMethod(method string) bool {
+	switch method {
+	case "PUT", "PATCH", "DELETE":
+		return true
+	}
+	return false
+}
+
+// withMethodOverride returns a shallow copy of req which is a POST request
+// with its X-HTTP-Method-Override header set to the original method. The
+// original request is not modified.
+func withMethodOverride(req *http.Request) *http.Request {
+	reqCopy := req.WithContext(req.Context())
+	reqCopy.Method = "POST"
+	reqCopy.Header = cloneHeader(req.Header)
+	if reqCopy.Header == nil {
+		reqCopy.Header = make(http.Header)
+	}
+	reqCopy.Header.Set("X-HTTP-Method-Override", req.Method)
+	return reqCopy
+}
@@ -0,0 +1,52 @@
+package sling
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMethodOverrideDoer(t *testing.T) {
+	cases := []struct {
+		sling             *Sling
+		status            int
+		expectedMethods   []string
+		expectedOverrides []string
+		expectedBodies    []string
+	}{
+		{New().Patch("http://example.com/").BodyJSON(map[string]int{"a": 1}), 405, []string{"PATCH", "POST"}, []string{"", "PATCH"}, []string{"{\"a\":1}\n", "{\"a\":1}\n"}},
+		{New().Delete("http://example.com/"), 501, []string{"DELETE", "POST"}, []string{"", "DELETE"}, []string{"", ""}},
+		{New().Put("http://example.com/"), 405, []string{"PUT", "POST"}, []string{"", "PUT"}, []string{"", ""}},
+		// other statuses and methods are not retried
+		{New().Delete("http://example.com/"), 404, []string{"DELETE"}, []string{""}, []string{""}},
+		{New().Head("http://example.com/"), 405, []string{"HEAD"}, []string{""}, []string{""}},
+		// bodies which can't be replayed are not retried
+		{New().Patch("http://example.com/").Body(struct{ *strings.Reader }{strings.NewReader("raw")}), 405, []string{"PATCH"}, []string{""}, []string{"raw"}},
+	}
+	for _, c := range cases {
+		// the original method is rejected with status, the override accepted
+		rec := &stubDoer{statuses: []int{c.status, http.StatusOK}}
+		req, _ := c.sling.Request()
+		_, err := MethodOverrideDoer(rec).Do(req)
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+		}
+		methods := make([]string, len(rec.requests))
+		for i, recorded := range rec.requests {
+			methods[i] = recorded.Method
+		}
+		if !reflect.DeepEqual(c.expectedMethods, methods) {
+			t.Errorf("expected methods %v, got %v", c.expectedMethods, methods)
+		}
+		if overrides := rec.headers("X-HTTP-Method-Override"); !reflect.DeepEqual(c.expectedOverrides, overrides) {
+			t.Errorf("expected overrides %v, got %v", c.expectedOverrides, overrides)
+		}
+		if !reflect.DeepEqual(c.expectedBodies, rec.bodies) {
+			t.Errorf("expected bodies %q, got %q", c.expectedBodies, rec.bodies)
+		}
+		if req.Header.Get("X-HTTP-Method-Override") != "" {
+			t.Errorf("expected original request Header to be unmodified, got %v", req.Header)
+		}
+	}
+}
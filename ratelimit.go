@@ -0,0 +1,76 @@
+package sling
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit is the rate limit state reported by response headers.
+type RateLimit struct {
+	// Limit is the number of requests allowed in the window
+	Limit int
+	// Remaining is the number of requests left in the window
+	Remaining int
+	// Reset is when the window resets, or the zero Time if not reported
+	Reset time.Time
+}
+
+// rateLimitHeaders names a family of rate limit headers and whether its
+// reset header is in seconds from now rather than a unix time.
+var rateLimitHeaders = []struct {
+	limit, remaining, reset string
+	resetIsDelta            bool
+}{
+	// IETF draft (draft-ietf-httpapi-ratelimit-headers)
+	{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", true},
+	// Github style
+	{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", false},
+	// Twitter style
+	{"X-Rate-Limit-Limit", "X-Rate-Limit-Remaining", "X-Rate-Limit-Reset", false},
+}
+
+// ParseRateLimit parses the rate limit headers of a response, supporting the
+// IETF draft RateLimit-* headers and the Github (X-RateLimit-*) and Twitter
+// (X-Rate-Limit-*) styles. Returns false unless the header has both the
+// limit and remaining headers of a recognized style.
+func ParseRateLimit(header http.Header) (*RateLimit, bool) {
+	return parseRateLimit(header, time.Now())
+}
+
+// parseRateLimit parses rate limit headers, resolving relative reset
+// values against now.
+func parseRateLimit(header http.Header, now time.Time) (*RateLimit, bool) {
+	for _, names := range rateLimitHeaders {
+		limit, limitOK := parseRateLimitValue(header.Get(names.limit))
+		remaining, remainingOK := parseRateLimitValue(header.Get(names.remaining))
+		// a missing limit or remaining isn't reported as 0
+		if !limitOK || !remainingOK {
+			continue
+		}
+		rateLimit := &RateLimit{Limit: limit, Remaining: remaining}
+		if reset, ok := parseRateLimitValue(header.Get(names.reset)); ok {
+			if names.resetIsDelta {
+				rateLimit.Reset = now.Add(time.Duration(reset) * time.Second)
+			} else {
+				rateLimit.Reset = time.Unix(int64(reset), 0)
+			}
+		}
+		return rateLimit, true
+	}
+	return nil, false
+}
+
+// parseRateLimitValue parses the leading integer of a rate limit header
+// value. Trailing policies (e.g. "100, 100;w=60") are ignored.
+func parseRateLimitValue(value string) (int, bool) {
+	if i := strings.IndexAny(value, ",;"); i >= 0 {
+		value = value[:i]
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
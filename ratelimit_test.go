@@ -0,0 +1,58 @@
+package sling
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	cases := []struct {
+		header   http.Header
+		expected *RateLimit
+	}{
+		// IETF draft, reset is delta seconds
+		{http.Header{"Ratelimit-Limit": []string{"100"}, "Ratelimit-Remaining": []string{"50"}, "Ratelimit-Reset": []string{"30"}},
+			&RateLimit{Limit: 100, Remaining: 50, Reset: now.Add(30 * time.Second)}},
+		// quota policies are ignored
+		{http.Header{"Ratelimit-Limit": []string{"100, 100;w=60"}, "Ratelimit-Remaining": []string{"99"}},
+			&RateLimit{Limit: 100, Remaining: 99}},
+		// Github, reset is a unix time
+		{http.Header{"X-Ratelimit-Limit": []string{"5000"}, "X-Ratelimit-Remaining": []string{"4999"}, "X-Ratelimit-Reset": []string{"1500000600"}},
+			&RateLimit{Limit: 5000, Remaining: 4999, Reset: time.Unix(1500000600, 0)}},
+		// Twitter
+		{http.Header{"X-Rate-Limit-Limit": []string{"15"}, "X-Rate-Limit-Remaining": []string{"0"}, "X-Rate-Limit-Reset": []string{"1500000900"}},
+			&RateLimit{Limit: 15, Remaining: 0, Reset: time.Unix(1500000900, 0)}},
+		// no or unparseable headers
+		{http.Header{}, nil},
+		{http.Header{"X-Ratelimit-Limit": []string{"lots"}}, nil},
+		// both limit and remaining are required
+		{http.Header{"X-Ratelimit-Limit": []string{"5000"}}, nil},
+		{http.Header{"Ratelimit-Remaining": []string{"0"}, "Ratelimit-Reset": []string{"30"}}, nil},
+		{http.Header{"X-Ratelimit-Limit": []string{"5000"}, "X-Ratelimit-Remaining": []string{"soon"}}, nil},
+	}
+	for _, c := range cases {
+		rateLimit, ok := parseRateLimit(c.header, now)
+		if ok != (c.expected != nil) {
+			t.Errorf("expected ok %v, got %v for %v", c.expected != nil, ok, c.header)
+		}
+		if !reflect.DeepEqual(c.expected, rateLimit) {
+			t.Errorf("not DeepEqual: expected %+v, got %+v", c.expected, rateLimit)
+		}
+	}
+}
+
+func TestParseRateLimit_canonicalKeys(t *testing.T) {
+	header := make(http.Header)
+	header.Set("x-ratelimit-limit", "60")
+	header.Set("x-ratelimit-remaining", "59")
+	rateLimit, ok := ParseRateLimit(header)
+	if !ok {
+		t.Fatalf("expected rate limit headers to be found")
+	}
+	if rateLimit.Limit != 60 || rateLimit.Remaining != 59 {
+		t.Errorf("expected 60/59, got %d/%d", rateLimit.Limit, rateLimit.Remaining)
+	}
+}
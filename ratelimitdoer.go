@@ -0,0 +1,88 @@
+package sling
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// rateLimitDoer is a Doer which throttles requests with a token bucket.
+type rateLimitDoer struct {
+	next   Doer
+	bucket *tokenBucket
+}
+
+// RateLimitDoer returns a Doer which does requests with next, throttled to
+// rate requests per second with bursts of up to burst requests. Requests
+// over the limit wait for their turn, or return the request context's error
+// if it is done first. A single RateLimitDoer may be shared by Slings (and
+// goroutines) to limit their combined rate; use one per host to limit hosts
+// independently. With a rate of 0, only the initial burst is allowed. If
+// next is nil, the http.DefaultClient will be used.
+func RateLimitDoer(next Doer, rate float64, burst int) Doer {
+	if next == nil {
+		next = http.DefaultClient
+	}
+	return &rateLimitDoer{next: next, bucket: newTokenBucket(rate, burst)}
+}
+
+func (d *rateLimitDoer) Do(req *http.Request) (*http.Response, error) {
+	if wait := d.bucket.reserve(); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			d.bucket.cancel()
+			return nil, req.Context().Err()
+		}
+	}
+	return d.next.Do(req)
+}
+
+// tokenBucket is a token bucket which hands out reservations, letting the
+// token count go negative so that waiters are served in order.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// newTokenBucket returns a full tokenBucket which refills at rate tokens per
+// second up to burst tokens.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	now := time.Now
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now(), now: now}
+}
+
+// reserve takes a token and returns how long to wait before using it.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	if b.rate <= 0 {
+		// the bucket never refills
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token which won't be used.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+}
@@ -0,0 +1,77 @@
+package sling
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	clock := time.Unix(1500000000, 0)
+	bucket := newTokenBucket(10, 2)
+	bucket.now = func() time.Time { return clock }
+	bucket.last = clock
+
+	// bursts are allowed, then waiters queue up
+	expectedWaits := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond}
+	for _, expected := range expectedWaits {
+		if wait := bucket.reserve(); wait != expected {
+			t.Errorf("expected wait %v, got %v", expected, wait)
+		}
+	}
+	// canceled reservations are returned
+	bucket.cancel()
+	if wait := bucket.reserve(); wait != 200*time.Millisecond {
+		t.Errorf("expected wait %v, got %v", 200*time.Millisecond, wait)
+	}
+	// tokens refill over time, up to the burst size
+	clock = clock.Add(time.Hour)
+	expectedWaits = []time.Duration{0, 0, 100 * time.Millisecond}
+	for _, expected := range expectedWaits {
+		if wait := bucket.reserve(); wait != expected {
+			t.Errorf("expected wait %v, got %v", expected, wait)
+		}
+	}
+}
+
+func TestRateLimitDoer(t *testing.T) {
+	calls := 0
+	next := doerFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	})
+	doer := RateLimitDoer(next, 100, 1)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		req, _ := New().Get("http://example.com/").Request()
+		if _, err := doer.Do(req); err != nil {
+			t.Errorf("expected nil, got %v", err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+	// the 2nd and 3rd requests wait 10ms each
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("expected requests to be throttled, took %v", elapsed)
+	}
+}
+
+func TestRateLimitDoer_contextDone(t *testing.T) {
+	next := doerFunc(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("request should not have been sent")
+		return nil, nil
+	})
+	doer := RateLimitDoer(next, 0, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := New().Get("http://example.com/").Request()
+	resp, err := doer.Do(req.WithContext(ctx))
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if resp != nil {
+		t.Errorf("expected nil resp, got %v", resp)
+	}
+}
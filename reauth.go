@@ -0,0 +1,138 @@
+package sling
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// maxReauthCredentials is the number of refreshed Authorization values a
+// reauthDoer remembers.
+const maxReauthCredentials = 64
+
+// reauthDoer is a Doer which refreshes the Authorization header and
+// retries once when a request is unauthorized.
+type reauthDoer struct {
+	next    Doer
+	refresh func(req *http.Request) (string, error)
+	mu      sync.Mutex
+	// refreshed Authorization values, keyed by the value they replace, and
+	// the keys in the order they were added
+	auths map[string]string
+	order []string
+	// refreshes in progress, keyed by the value they replace
+	calls map[string]*refreshCall
+}
+
+// refreshCall is a refresh shared by concurrent requests with the same
+// original Authorization.
+type refreshCall struct {
+	done chan struct{}
+	auth string
+	err  error
+}
+
+// ReauthDoer returns a Doer which does requests with next and, when a
+// response is 401 Unauthorized, calls refresh to get a new Authorization
+// header value (e.g. "Bearer <token>") and retries the request exactly once
+// with it. Later requests through this Doer which carry the same original
+// Authorization are sent with the refreshed value instead, so a Doer can be
+// shared by Slings with different credentials. Only the latest value for the
+// 64 most recently refreshed credentials is kept. Concurrent 401s for the same
+// credential share one call to refresh. If refresh fails, the 401 response is
+// returned along with the error. If a request Body can't be replayed with
+// GetBody (see Request()), the 401 response is returned. If next is nil, the
+// http.DefaultClient will be used.
+func ReauthDoer(next Doer, refresh func(req *http.Request) (string, error)) Doer {
+	if next == nil {
+		next = http.DefaultClient
+	}
+	return &reauthDoer{
+		next:    next,
+		refresh: refresh,
+		auths:   make(map[string]string),
+		calls:   make(map[string]*refreshCall),
+	}
+}
+
+func (d *reauthDoer) Do(req *http.Request) (*http.Response, error) {
+	original := req.Header.Get("Authorization")
+	d.mu.Lock()
+	sent, ok := d.auths[original]
+	d.mu.Unlock()
+	if ok {
+		req = withAuthorization(req, sent)
+	} else {
+		sent = original
+	}
+	resp, err := d.next.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	retryReq, ok := retryRequest(req)
+	if !ok {
+		return resp, nil
+	}
+	auth, err := d.refreshAuth(req, original, sent)
+	if err != nil {
+		return resp, fmt.Errorf("sling: refreshing authorization: %w", err)
+	}
+	DrainAndClose(resp)
+	return d.next.Do(withAuthorization(retryReq, auth))
+}
+
+// refreshAuth returns the Authorization value which replaces original, after
+// the value sent was rejected. If the value has been refreshed since it was
+// sent, it is returned without refreshing again, and concurrent callers for
+// the same original value share one call to refresh.
+func (d *reauthDoer) refreshAuth(req *http.Request, original, sent string) (string, error) {
+	d.mu.Lock()
+	if auth, ok := d.auths[original]; ok && auth != sent {
+		d.mu.Unlock()
+		return auth, nil
+	}
+	if call, ok := d.calls[original]; ok {
+		d.mu.Unlock()
+		<-call.done
+		return call.auth, call.err
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	d.calls[original] = call
+	d.mu.Unlock()
+
+	call.auth, call.err = d.refresh(req)
+
+	d.mu.Lock()
+	delete(d.calls, original)
+	if call.err == nil {
+		d.store(original, call.auth)
+	}
+	d.mu.Unlock()
+	close(call.done)
+	return call.auth, call.err
+}
+
+// store records auth as the value which replaces original, forgetting the
+// oldest value once maxReauthCredentials are stored. d.mu must be held.
+func (d *reauthDoer) store(original, auth string) {
+	if _, ok := d.auths[original]; !ok {
+		if len(d.order) == maxReauthCredentials {
+			delete(d.auths, d.order[0])
+			d.order = append(d.order[:0], d.order[1:]...)
+		}
+		d.order = append(d.order, original)
+	}
+	d.auths[original] = auth
+}
+
+// withAuthorization returns a shallow copy of req with its Authorization
+// header set to auth. The original request's Header is not modified.
+func withAuthorization(req *http.Request, auth string) *http.Request {
+	reqCopy := req.WithContext(req.Context())
+	reqCopy.Header = cloneHeader(req.Header)
+	if reqCopy.Header == nil {
+		reqCopy.Header = make(http.Header)
+	}
+	reqCopy.Header.Set("Authorization", auth)
+	return reqCopy
+}
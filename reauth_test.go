@@ -0,0 +1,182 @@
+package sling
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReauthDoer(t *testing.T) {
+	rec := &stubDoer{statuses: []int{401, 200}}
+	refreshes := 0
+	doer := ReauthDoer(rec, func(req *http.Request) (string, error) {
+		refreshes++
+		return "Bearer new", nil
+	})
+	sling := New().Doer(doer).Post("http://example.com/").Set("Authorization", "Bearer old").BodyForm(paramsA)
+
+	resp, err := sling.Receive(nil, nil)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("expected 200 response, got %v, %v", resp, err)
+	}
+	expectedAuths := []string{"Bearer old", "Bearer new"}
+	if auths := rec.headers("Authorization"); !reflect.DeepEqual(expectedAuths, auths) {
+		t.Errorf("expected Authorizations %v, got %v", expectedAuths, auths)
+	}
+	// the body is replayed for the retry
+	if rec.bodies[1] != "limit=30" {
+		t.Errorf("expected body %s, got %s", "limit=30", rec.bodies[1])
+	}
+	// later requests with the same Authorization use the refreshed value
+	resp, err = sling.Receive(nil, nil)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("expected 200 response, got %v, %v", resp, err)
+	}
+	if auths := rec.headers("Authorization"); refreshes != 1 || len(auths) != 3 || auths[2] != "Bearer new" {
+		t.Errorf("expected refreshed Authorization to be reused, got %v after %d refreshes", auths, refreshes)
+	}
+	// the Sling's header is not modified
+	if value := sling.header.Get("Authorization"); value != "Bearer old" {
+		t.Errorf("expected Sling Authorization %s, got %s", "Bearer old", value)
+	}
+}
+
+func TestReauthDoer_sharedByCredentials(t *testing.T) {
+	rec := &stubDoer{statuses: []int{401, 200}}
+	doer := ReauthDoer(rec, func(req *http.Request) (string, error) {
+		return req.Header.Get("Authorization") + "-new", nil
+	})
+	base := New().Doer(doer).Get("http://example.com/")
+	tenantA := base.New().Set("Authorization", "Bearer a")
+	tenantB := base.New().Set("Authorization", "Bearer b")
+
+	for _, sling := range []*Sling{tenantA, tenantB, tenantA} {
+		resp, err := sling.Receive(nil, nil)
+		if err != nil || resp.StatusCode != 200 {
+			t.Fatalf("expected 200 response, got %v, %v", resp, err)
+		}
+	}
+	// tenant B's requests keep their own Authorization
+	expectedAuths := []string{"Bearer a", "Bearer a-new", "Bearer b", "Bearer a-new"}
+	if auths := rec.headers("Authorization"); !reflect.DeepEqual(expectedAuths, auths) {
+		t.Errorf("expected Authorizations %v, got %v", expectedAuths, auths)
+	}
+}
+
+func TestReauthDoer_retriesOnce(t *testing.T) {
+	rec := &stubDoer{statuses: []int{401}}
+	doer := ReauthDoer(rec, func(req *http.Request) (string, error) {
+		return "Bearer new", nil
+	})
+	req, _ := New().Get("http://example.com/").Request()
+	resp, err := doer.Do(req)
+	if err != nil || resp.StatusCode != 401 {
+		t.Fatalf("expected 401 response, got %v, %v", resp, err)
+	}
+	if rec.count() != 2 {
+		t.Errorf("expected 2 attempts, got %d", rec.count())
+	}
+}
+
+func TestReauthDoer_refreshError(t *testing.T) {
+	rec := &stubDoer{statuses: []int{401}}
+	refreshErr := errors.New("refresh failure")
+	doer := ReauthDoer(rec, func(req *http.Request) (string, error) {
+		return "", refreshErr
+	})
+	resp, err := New().Doer(doer).Get("http://example.com/").Receive(nil, nil)
+	if !errors.Is(err, refreshErr) {
+		t.Errorf("expected %v, got %v", refreshErr, err)
+	}
+	if resp == nil || resp.StatusCode != 401 {
+		t.Errorf("expected 401 response, got %v", resp)
+	}
+	if rec.count() != 1 {
+		t.Errorf("expected 1 attempt, got %d", rec.count())
+	}
+}
+
+func TestReauthDoer_unreplayableBody(t *testing.T) {
+	rec := &stubDoer{statuses: []int{401}}
+	doer := ReauthDoer(rec, func(req *http.Request) (string, error) {
+		return "Bearer new", nil
+	})
+	req, _ := New().Post("http://example.com/").Body(struct{ *strings.Reader }{strings.NewReader("raw")}).Request()
+	resp, err := doer.Do(req)
+	if err != nil || resp.StatusCode != 401 {
+		t.Errorf("expected 401 response, got %v, %v", resp, err)
+	}
+	if rec.count() != 1 {
+		t.Errorf("expected 1 attempt, got %d", rec.count())
+	}
+}
+
+func TestReauthDoer_concurrentRefresh(t *testing.T) {
+	const requests = 4
+	var arrived sync.WaitGroup
+	arrived.Add(requests)
+	rec := &stubDoer{respond: func(attempt int, req *http.Request) (*http.Response, error) {
+		if req.Header.Get("Authorization") == "Bearer new" {
+			return testResponse(http.StatusOK, nil), nil
+		}
+		// reject the old Authorization once every request has been sent
+		arrived.Done()
+		arrived.Wait()
+		return testResponse(http.StatusUnauthorized, nil), nil
+	}}
+	var refreshes int32
+	doer := ReauthDoer(rec, func(req *http.Request) (string, error) {
+		atomic.AddInt32(&refreshes, 1)
+		return "Bearer new", nil
+	})
+	sling := New().Doer(doer).Get("http://example.com/").Set("Authorization", "Bearer old")
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := sling.New().Receive(nil, nil)
+			if err != nil || resp.StatusCode != 200 {
+				t.Errorf("expected 200 response, got %v, %v", resp, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if refreshes != 1 {
+		t.Errorf("expected 1 refresh, got %d", refreshes)
+	}
+}
+
+func TestReauthDoer_forgetsOldestCredential(t *testing.T) {
+	rec := &stubDoer{respond: func(attempt int, req *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(req.Header.Get("Authorization"), "-new") {
+			return testResponse(http.StatusOK, nil), nil
+		}
+		return testResponse(http.StatusUnauthorized, nil), nil
+	}}
+	doer := ReauthDoer(rec, func(req *http.Request) (string, error) {
+		return req.Header.Get("Authorization") + "-new", nil
+	})
+	for i := 0; i <= maxReauthCredentials; i++ {
+		req, _ := New().Get("http://example.com/").Set("Authorization", fmt.Sprintf("Bearer %d", i)).Request()
+		if _, err := doer.Do(req); err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+	}
+	auths := doer.(*reauthDoer).auths
+	if len(auths) != maxReauthCredentials {
+		t.Errorf("expected %d Authorizations, got %d", maxReauthCredentials, len(auths))
+	}
+	if _, ok := auths["Bearer 0"]; ok {
+		t.Errorf("expected oldest Authorization to be forgotten")
+	}
+	if auth := auths["Bearer 64"]; auth != "Bearer 64-new" {
+		t.Errorf("expected %s, got %s", "Bearer 64-new", auth)
+	}
+}
@@ -0,0 +1,110 @@
+package sling
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retryAfterDoer is a Doer which retries responses after the delay given by
+// their Retry-After header.
+type retryAfterDoer struct {
+	next        Doer
+	maxRetries  int
+	shouldRetry func(resp *http.Response) bool
+}
+
+// RetryAfterDoer returns a Doer which does requests with next and retries
+// responses which have a Retry-After header (in seconds or as an HTTP-date),
+// after waiting the indicated delay, up to maxRetries times. The
+// shouldRetry func decides which responses are retried. If it is nil, 429
+// Too Many Requests and 503 Service Unavailable responses are retried. This
+// is independent of which responses Do decodes as successes (see
+// IsSuccess). The response is returned as is if the delay would pass the
+// request context's deadline, or if a request Body can't be replayed with
+// GetBody (see Request()). If next is nil, the http.DefaultClient will be
+// used.
+func RetryAfterDoer(next Doer, maxRetries int, shouldRetry func(resp *http.Response) bool) Doer {
+	if next == nil {
+		next = http.DefaultClient
+	}
+	if shouldRetry == nil {
+		shouldRetry = isRetryAfterStatus
+	}
+	return &retryAfterDoer{next: next, maxRetries: maxRetries, shouldRetry: shouldRetry}
+}
+
+// isRetryAfterStatus returns true for 429 Too Many Requests and 503 Service
+// Unavailable responses.
+func isRetryAfterStatus(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+}
+
+func (d *retryAfterDoer) Do(req *http.Request) (*http.Response, error) {
+	for retries := 0; ; retries++ {
+		resp, err := d.next.Do(req)
+		if err != nil || retries >= d.maxRetries {
+			return resp, err
+		}
+		if !d.shouldRetry(resp) {
+			return resp, nil
+		}
+		wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			return resp, nil
+		}
+		if deadline, ok := req.Context().Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return resp, nil
+		}
+		retryReq, ok := retryRequest(req)
+		if !ok {
+			return resp, nil
+		}
+		DrainAndClose(resp)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+		req = retryReq
+	}
+}
+
+// retryRequest returns a copy of req to send again, with a fresh Body from
+// GetBody. Returns false if the Body can't be replayed.
+func retryRequest(req *http.Request) (*http.Request, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	retryReq, err := copyRequest(req)
+	return retryReq, err == nil
+}
+
+// parseRetryAfter parses a Retry-After header value, either delay seconds or
+// an HTTP-date, into the duration to wait from now.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if wait := date.Sub(now); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
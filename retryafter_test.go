@@ -0,0 +1,146 @@
+package sling
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC)
+	cases := []struct {
+		value        string
+		expectedWait time.Duration
+		expectedOK   bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{"Wed, 21 Oct 2015 07:28:30 GMT", 30 * time.Second, true},
+		// dates in the past mean retry now
+		{"Wed, 21 Oct 2015 07:27:00 GMT", 0, true},
+		{"", 0, false},
+		{"-5", 0, false},
+		{"soon", 0, false},
+	}
+	for _, c := range cases {
+		wait, ok := parseRetryAfter(c.value, now)
+		if wait != c.expectedWait || ok != c.expectedOK {
+			t.Errorf("expected %v %v, got %v %v for %q", c.expectedWait, c.expectedOK, wait, ok, c.value)
+		}
+	}
+}
+
+func TestRetryAfterDoer(t *testing.T) {
+	cases := []struct {
+		statuses         []int
+		retryAfter       string
+		maxRetries       int
+		shouldRetry      func(resp *http.Response) bool
+		expectedStatus   int
+		expectedAttempts int
+	}{
+		{[]int{429, 503, 200}, "0", 3, nil, 200, 3},
+		// retries are bounded
+		{[]int{429, 429, 200}, "0", 1, nil, 429, 2},
+		// without Retry-After, responses are returned
+		{[]int{503, 200}, "", 3, nil, 503, 1},
+		// other statuses are not retried
+		{[]int{500, 200}, "0", 3, nil, 500, 1},
+		// unless shouldRetry says so
+		{[]int{202, 200}, "0", 3, func(resp *http.Response) bool { return resp.StatusCode == 202 }, 200, 2},
+		{[]int{503, 200}, "0", 3, func(resp *http.Response) bool { return false }, 503, 1},
+	}
+	for _, c := range cases {
+		next := &stubDoer{statuses: c.statuses, header: http.Header{}}
+		if c.retryAfter != "" {
+			next.header.Set("Retry-After", c.retryAfter)
+		}
+		req, _ := New().Post("http://example.com/").BodyForm(paramsA).Request()
+		resp, err := RetryAfterDoer(next, c.maxRetries, c.shouldRetry).Do(req)
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+			continue
+		}
+		if resp.StatusCode != c.expectedStatus {
+			t.Errorf("expected %d, got %d", c.expectedStatus, resp.StatusCode)
+		}
+		if len(next.bodies) != c.expectedAttempts {
+			t.Errorf("expected %d attempts, got %d", c.expectedAttempts, len(next.bodies))
+		}
+		// bodies are replayed for each attempt
+		for _, body := range next.bodies {
+			if body != "limit=30" {
+				t.Errorf("expected body %s, got %s", "limit=30", body)
+			}
+		}
+	}
+}
+
+func TestRetryAfterDoer_contextCanceled(t *testing.T) {
+	next := &stubDoer{statuses: []int{429, 200}, header: http.Header{"Retry-After": []string{"60"}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	req, _ := New().Get("http://example.com/").Request()
+	resp, err := RetryAfterDoer(next, 1, nil).Do(req.WithContext(ctx))
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if resp != nil {
+		t.Errorf("expected nil resp, got %v", resp)
+	}
+}
+
+func TestRetryAfterDoer_deadline(t *testing.T) {
+	next := &stubDoer{statuses: []int{503, 200}, header: http.Header{"Retry-After": []string{"60"}}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, _ := New().Get("http://example.com/").Request()
+	resp, err := RetryAfterDoer(next, 3, nil).Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	// waiting would pass the deadline, so the response is returned
+	if resp.StatusCode != 503 || next.count() != 1 {
+		t.Errorf("expected a single 503 response, got %d after %d attempts", resp.StatusCode, next.count())
+	}
+}
+
+func TestRetryAfterDoer_unreplayableBody(t *testing.T) {
+	next := &stubDoer{statuses: []int{429, 200}, header: http.Header{"Retry-After": []string{"0"}}}
+	body := struct{ *strings.Reader }{strings.NewReader("raw")}
+	req, _ := New().Post("http://example.com/").Body(body).Request()
+	resp, err := RetryAfterDoer(next, 3, nil).Do(req)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if resp.StatusCode != 429 || next.count() != 1 {
+		t.Errorf("expected a single 429 response, got %d after %d attempts", resp.StatusCode, next.count())
+	}
+}
+
+func TestRetryAfterDoer_server(t *testing.T) {
+	client, mux, server := testServer()
+	defer server.Close()
+	attempts := 0
+	mux.HandleFunc("/busy", func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(503)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"text": "ready"}`)
+	})
+	model := new(FakeModel)
+	resp, err := New().Doer(RetryAfterDoer(client, 1, nil)).Get("http://example.com/busy").Receive(model, nil)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("expected 200 response, got %v, %v", resp, err)
+	}
+	if model.Text != "ready" {
+		t.Errorf("expected %s, got %s", "ready", model.Text)
+	}
+}
@@ -1,13 +1,22 @@
 package sling
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
 
 	goquery "github.com/google/go-querystring/query"
 )
@@ -16,8 +25,35 @@ const (
 	contentType     = "Content-Type"
 	jsonContentType = "application/json"
 	formContentType = "application/x-www-form-urlencoded"
+	// maxDrainBytes is the most of a response Body read by DrainAndClose.
+	// Larger bodies are cheaper to abandon along with their connection.
+	maxDrainBytes = 64 << 10
 )
 
+// ErrBodyReadTimeout is returned by Do when reading the response Body takes
+// longer than the Sling's BodyReadTimeout.
+var ErrBodyReadTimeout = errors.New("sling: timeout reading response body")
+
+// ContentLengthUnknown may be passed to ContentLength to send the request
+// Body with an unknown length (i.e. chunked), even if its length is known.
+const ContentLengthUnknown int64 = -1
+
+// BodyProvider provides a body value when a request is created. Pass a
+// BodyProvider to BodyJSON or BodyForm to compute the body at request
+// creation time (e.g. to include fresh timestamps or nonces) rather than
+// when the Sling is configured. The Request's GetBody replays the body
+// encoded when the Request was created, as net/http expects when it rewinds
+// bodies for redirects and retries on reused connections. The Doers in
+// this package which retry requests (RetryAfterDoer, ReauthDoer,
+// MethodOverrideDoer, and HedgeDoer) call the provider again instead, so
+// each of their attempts sends a freshly computed body, unless the
+// Request's context has been replaced.
+type BodyProvider func() (interface{}, error)
+
+// ValuesEncoder encodes a value as url.Values. The default, go-querystring's
+// query.Values, encodes url tagged structs.
+type ValuesEncoder func(v interface{}) (url.Values, error)
+
 // Doer executes http requests.  It is implemented by *http.Client.  You can
 // wrap *http.Client with layers of Doers to form a stack of client-side
 // middleware.
@@ -35,8 +71,14 @@ type Sling struct {
 	rawURL string
 	// stores key-values pairs to add to request's Headers
 	header http.Header
+	// stores key-values pairs to add to request's Headers if not in header
+	defaultHeader http.Header
 	// url tagged query structs
 	queryStructs []interface{}
+	// encoder for query structs and form bodies, nil means go-querystring
+	valuesEncoder ValuesEncoder
+	// pre-encoded query strings appended verbatim
+	rawQueries []string
 	// json tagged body struct
 	bodyJSON interface{}
 	// url tagged body struct (form)
@@ -45,6 +87,33 @@ type Sling struct {
 	body io.Reader
 	// flag to indent marshalled JSON
 	indentJSON bool
+	// scheme assumed for raw urls which don't specify one
+	defaultScheme string
+	// flags to reject relative or non-https request urls
+	requireAbsoluteURL bool
+	requireHTTPS       bool
+	// overrides the request ContentLength when non-nil
+	contentLength *int64
+	// limit on request body size in bytes, 0 means no limit
+	maxRequestBytes int64
+	// funcs applied to each new request, in order
+	interceptors []func(req *http.Request) error
+	// flag to decode responses without a Content-Type which look like JSON
+	sniffJSON bool
+	// flag to return redirect responses instead of following them
+	noRedirects bool
+	// limit on the time Do spends reading the response Body, 0 means none
+	bodyReadTimeout time.Duration
+	// funcs applied to each decoded response value, in order
+	afterUnmarshal []func(v interface{}, resp *http.Response) error
+	// prefix removed from the start of response bodies before decoding
+	stripPrefix string
+	// decides whether responses are decoded into successV, nil means 2XX
+	isSuccess func(resp *http.Response) bool
+	// context of new requests, nil means context.Background
+	ctx context.Context
+	// flag to drain response bodies before closing them
+	autoDrain bool
 }
 
 // New returns a new Sling with an http DefaultClient.
@@ -69,22 +138,43 @@ func New() *Sling {
 //
 // Note that query and body values are copied so if pointer values are used,
 // mutating the original value will mutate the value within the child Sling.
+//
+// New may be called concurrently on a shared parent Sling, as long as the
+// parent itself is no longer being modified.
 func (s *Sling) New() *Sling {
-	// copy Headers pairs into new Header map
-	headerCopy := make(http.Header)
-	for k, v := range s.header {
-		headerCopy[k] = v
+	// copy Headers pairs into new Header map, without sharing value slices
+	// which Add could otherwise append to for sibling Slings
+	headerCopy := cloneHeader(s.header)
+	if headerCopy == nil {
+		headerCopy = make(http.Header)
 	}
 	return &Sling{
-		httpClient:   s.httpClient,
-		method:       s.method,
-		rawURL:       s.rawURL,
-		header:       headerCopy,
-		queryStructs: append([]interface{}{}, s.queryStructs...),
-		bodyJSON:     s.bodyJSON,
-		bodyForm:     s.bodyForm,
-		body:         s.body,
-		indentJSON:   s.indentJSON,
+		httpClient:         s.httpClient,
+		method:             s.method,
+		rawURL:             s.rawURL,
+		header:             headerCopy,
+		defaultHeader:      cloneHeader(s.defaultHeader),
+		queryStructs:       append([]interface{}{}, s.queryStructs...),
+		valuesEncoder:      s.valuesEncoder,
+		rawQueries:         append([]string{}, s.rawQueries...),
+		bodyJSON:           s.bodyJSON,
+		bodyForm:           s.bodyForm,
+		body:               s.body,
+		indentJSON:         s.indentJSON,
+		defaultScheme:      s.defaultScheme,
+		requireAbsoluteURL: s.requireAbsoluteURL,
+		requireHTTPS:       s.requireHTTPS,
+		contentLength:      s.contentLength,
+		maxRequestBytes:    s.maxRequestBytes,
+		interceptors:       append([]func(req *http.Request) error{}, s.interceptors...),
+		sniffJSON:          s.sniffJSON,
+		noRedirects:        s.noRedirects,
+		bodyReadTimeout:    s.bodyReadTimeout,
+		afterUnmarshal:     append([]func(v interface{}, resp *http.Response) error{}, s.afterUnmarshal...),
+		stripPrefix:        s.stripPrefix,
+		isSuccess:          s.isSuccess,
+		ctx:                s.ctx,
+		autoDrain:          s.autoDrain,
 	}
 }
 
@@ -110,6 +200,15 @@ func (s *Sling) Doer(doer Doer) *Sling {
 	return s
 }
 
+// NoRedirects sets whether Do returns 3XX redirect responses as is, rather
+// than following them, without changing the redirect policy of the shared
+// http Client. It applies when the Doer is an *http.Client (the default),
+// other Doers are responsible for their own redirect handling.
+func (s *Sling) NoRedirects(b bool) *Sling {
+	s.noRedirects = b
+	return s
+}
+
 // Method
 
 // Head sets the Sling method to HEAD and sets the given pathURL.
@@ -164,6 +263,55 @@ func (s *Sling) Set(key, value string) *Sling {
 	return s
 }
 
+// HeaderTemplate sets the key header of each new request to the value of
+// the text/template tmpl, rendered when the request is created, for values
+// which depend on the request (e.g. trace IDs or signatures). The template
+// is executed with the value returned by data, which is given the created
+// request and its context. Template parsing and execution errors are
+// returned by Request.
+func (s *Sling) HeaderTemplate(key, tmpl string, data func(req *http.Request) (interface{}, error)) *Sling {
+	t, parseErr := template.New(key).Parse(tmpl)
+	return s.Interceptor(func(req *http.Request) error {
+		if parseErr != nil {
+			return parseErr
+		}
+		var value interface{}
+		if data != nil {
+			var err error
+			value, err = data(req)
+			if err != nil {
+				return err
+			}
+		}
+		buf := new(bytes.Buffer)
+		if err := t.Execute(buf, value); err != nil {
+			return err
+		}
+		req.Header.Set(key, buf.String())
+		return nil
+	})
+}
+
+// DefaultHeaders sets default header values, which are added to new
+// requests only for keys that aren't set with Add or Set. Values for keys
+// already in the defaults are replaced. Header keys are canonicalized.
+func (s *Sling) DefaultHeaders(header http.Header) *Sling {
+	if s.defaultHeader == nil {
+		s.defaultHeader = make(http.Header)
+	}
+	for key, values := range header {
+		s.defaultHeader[http.CanonicalHeaderKey(key)] = append([]string{}, values...)
+	}
+	return s
+}
+
+// RemoveDefaultHeader removes the default header values for key (see
+// DefaultHeaders), e.g. to suppress a default inherited from a parent Sling.
+func (s *Sling) RemoveDefaultHeader(key string) *Sling {
+	s.defaultHeader.Del(key)
+	return s
+}
+
 // SetBasicAuth sets the Authorization header to use HTTP Basic Authentication
 // with the provided username and password. With HTTP Basic Authentication
 // the provided username and password are not encrypted.
@@ -178,6 +326,22 @@ func basicAuth(username, password string) string {
 	return base64.StdEncoding.EncodeToString([]byte(auth))
 }
 
+// NoCacheHeaders sets the Cache-Control and Pragma headers to ask caches
+// between the client and server not to serve stored responses.
+func (s *Sling) NoCacheHeaders() *Sling {
+	return s.Set("Cache-Control", "no-cache").Set("Pragma", "no-cache")
+}
+
+// IdempotencyKey sets the Idempotency-Key header of each new request to a
+// key returned by newKey (e.g. a UUID generator), so servers can detect
+// retried requests. A request keeps its key if it is retried by a Doer.
+func (s *Sling) IdempotencyKey(newKey func() string) *Sling {
+	return s.Interceptor(func(req *http.Request) error {
+		req.Header.Set("Idempotency-Key", newKey())
+		return nil
+	})
+}
+
 // Url
 
 // Base sets the rawURL. If you intend to extend the url with Path,
@@ -187,18 +351,54 @@ func (s *Sling) Base(rawURL string) *Sling {
 	return s
 }
 
+// DefaultScheme sets the scheme (e.g. "https") assumed for a rawURL which
+// does not specify one, so a Base like "api.io:8443/v1/" is treated as
+// "https://api.io:8443/v1/" rather than being misparsed. Set it before
+// calling Path on a Base without a scheme, except for a Base which starts
+// with a host and port (e.g. "api.io:8443/v1/"), which Path keeps intact.
+func (s *Sling) DefaultScheme(scheme string) *Sling {
+	s.defaultScheme = scheme
+	return s
+}
+
 // Path extends the rawURL with the given path by resolving the reference to
 // an absolute URL. If parsing errors occur, the rawURL is left unmodified.
 func (s *Sling) Path(path string) *Sling {
-	baseURL, baseErr := url.Parse(s.rawURL)
+	rawURL := withDefaultScheme(s.rawURL, s.defaultScheme)
+	// resolve a host and port without a scheme (e.g. "api.io:8443/v1/"),
+	// which would otherwise parse as an opaque url with scheme "api.io", as
+	// a network-path reference so it is kept until DefaultScheme is set
+	hostOnly := isHostAndPort(rawURL)
+	if hostOnly {
+		rawURL = "//" + rawURL
+	}
+	baseURL, baseErr := url.Parse(rawURL)
 	pathURL, pathErr := url.Parse(path)
 	if baseErr == nil && pathErr == nil {
 		s.rawURL = baseURL.ResolveReference(pathURL).String()
+		if hostOnly {
+			s.rawURL = strings.TrimPrefix(s.rawURL, "//")
+		}
 		return s
 	}
 	return s
 }
 
+// RequireAbsoluteURL makes Request return an error if the final url is not
+// absolute (i.e. has no scheme or host), rather than creating a request
+// which cannot be sent.
+func (s *Sling) RequireAbsoluteURL() *Sling {
+	s.requireAbsoluteURL = true
+	return s
+}
+
+// RequireHTTPS makes Request return an error if the final url is not an
+// absolute https url.
+func (s *Sling) RequireHTTPS() *Sling {
+	s.requireHTTPS = true
+	return s
+}
+
 // QueryStruct appends the queryStruct to the Sling's queryStructs. The value
 // pointed to by each queryStruct will be encoded as url query parameters on
 // new requests (see Request()).
@@ -211,11 +411,32 @@ func (s *Sling) QueryStruct(queryStruct interface{}) *Sling {
 	return s
 }
 
+// RawQuery appends the pre-encoded query string rawQuery (e.g. "a=1;b=2")
+// verbatim to the query of new requests, after any query structs. Unlike
+// query structs, it is not re-encoded, for APIs with nonstandard or
+// signature-sensitive query syntax.
+func (s *Sling) RawQuery(rawQuery string) *Sling {
+	if rawQuery != "" {
+		s.rawQueries = append(s.rawQueries, rawQuery)
+	}
+	return s
+}
+
+// QueryEncoder sets the ValuesEncoder used to encode query structs and
+// form bodies (see BodyForm()) in place of go-querystring, for types which
+// need encodings the url tags can't express. If a nil encoder is given,
+// go-querystring will be used.
+func (s *Sling) QueryEncoder(encoder ValuesEncoder) *Sling {
+	s.valuesEncoder = encoder
+	return s
+}
+
 // Body
 
 // BodyJSON sets the Sling's bodyJSON. The value pointed to by the bodyJSON
 // will be JSON encoded as the Body on new requests (see Request()).
-// The bodyJSON argument should be a pointer to a JSON tagged struct. See
+// The bodyJSON argument should be a pointer to a JSON tagged struct or a
+// BodyProvider which returns one. See
 // https://golang.org/pkg/encoding/json/#MarshalIndent for details.
 func (s *Sling) BodyJSON(bodyJSON interface{}) *Sling {
 	if bodyJSON != nil {
@@ -233,7 +454,8 @@ func (s *Sling) IndentJSON(b bool) *Sling {
 
 // BodyForm sets the Sling's bodyForm. The value pointed to by the bodyForm
 // will be url encoded as the Body on new requests (see Request()).
-// The bodyStruct argument should be a pointer to a url tagged struct. See
+// The bodyForm argument should be a pointer to a url tagged struct or a
+// BodyProvider which returns one. See
 // https://godoc.org/github.com/google/go-querystring/query for details.
 func (s *Sling) BodyForm(bodyForm interface{}) *Sling {
 	if bodyForm != nil {
@@ -254,20 +476,65 @@ func (s *Sling) Body(body io.Reader) *Sling {
 	return s
 }
 
+// ContentLength sets the ContentLength of new requests, overriding the
+// length http.NewRequest determines for bytes and strings bodies. Pass
+// ContentLengthUnknown to stream the body with chunked encoding. See
+// http.Request ContentLength for how 0 is treated.
+func (s *Sling) ContentLength(n int64) *Sling {
+	s.contentLength = &n
+	return s
+}
+
+// MaxRequestBytes limits the size of request bodies to n bytes, to catch
+// bugs which would otherwise upload huge bodies. Request returns an error
+// for bodies of known length over the limit, while bodies of unknown length
+// fail with an error when reading past the limit while being sent. A limit
+// of 0 means no limit.
+func (s *Sling) MaxRequestBytes(n int64) *Sling {
+	s.maxRequestBytes = n
+	return s
+}
+
+// Context sets the context of new requests (see Request()), so a tree of
+// Slings can carry cancellation, deadlines, and values to the requests
+// Receive sends. Requests passed to Do are sent with their own context.
+func (s *Sling) Context(ctx context.Context) *Sling {
+	s.ctx = ctx
+	return s
+}
+
+// Interceptor appends a func which is applied to each new request after it
+// has been fully created (see Request()), for last-mile changes like
+// canonicalizing the query for a signature. Interceptors are applied in the
+// order they were added and any error they return is returned by Request.
+func (s *Sling) Interceptor(interceptor func(req *http.Request) error) *Sling {
+	if interceptor != nil {
+		s.interceptors = append(s.interceptors, interceptor)
+	}
+	return s
+}
+
 // Requests
 
 // Request returns a new http.Request created with the Sling properties.
 // Returns any errors parsing the rawURL, encoding query structs, encoding
-// the body, or creating the http.Request.
+// the body, creating the http.Request, or applying interceptors.
+// JSON and form bodies are encoded into memory, so the Request's GetBody is
+// set and redirects can replay them.
 func (s *Sling) Request() (*http.Request, error) {
-	reqURL, err := url.Parse(s.rawURL)
+	reqURL, err := url.Parse(withDefaultScheme(s.rawURL, s.defaultScheme))
 	if err != nil {
 		return nil, err
 	}
-	err = addQueryStructs(reqURL, s.queryStructs)
+	err = s.checkURL(reqURL)
 	if err != nil {
 		return nil, err
 	}
+	err = addQueryStructs(reqURL, s.queryStructs, s.valuesEncoder)
+	if err != nil {
+		return nil, err
+	}
+	addRawQueries(reqURL, s.rawQueries)
 	body, err := s.getRequestBody()
 	if err != nil {
 		return nil, err
@@ -276,21 +543,135 @@ func (s *Sling) Request() (*http.Request, error) {
 	if err != nil {
 		return nil, err
 	}
+	if s.ctx != nil {
+		req = req.WithContext(s.ctx)
+	}
+	if s.contentLength != nil {
+		req.ContentLength = *s.contentLength
+	}
+	if s.maxRequestBytes > 0 && req.Body != nil && req.Body != http.NoBody {
+		err = limitRequestBody(req, s.maxRequestBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.hasBodyProvider() {
+		req = req.WithContext(context.WithValue(req.Context(), bodyProviderKey{}, s.bodyProviderGetBody()))
+	}
 	addHeaders(req, s.header)
+	addDefaultHeaders(req, s.defaultHeader)
+	for _, interceptor := range s.interceptors {
+		if err := interceptor(req); err != nil {
+			return nil, err
+		}
+	}
 	return req, err
 }
 
-// addQueryStructs parses url tagged query structs using go-querystring to
-// encode them to url.Values and format them onto the url.RawQuery. Any
-// query parsing or encoding errors are returned.
-func addQueryStructs(reqURL *url.URL, queryStructs []interface{}) error {
+// limitRequestBody returns an error if the request body is known to be over
+// n bytes and otherwise limits reading the body (and any copies from
+// GetBody) to n bytes.
+func limitRequestBody(req *http.Request, n int64) error {
+	if req.ContentLength > n {
+		return bodyTooLargeError(n)
+	}
+	req.Body = &limitedBody{ReadCloser: req.Body, limit: n}
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return &limitedBody{ReadCloser: body, limit: n}, nil
+		}
+	}
+	return nil
+}
+
+// bodyTooLargeError returns the error for request bodies over n bytes.
+func bodyTooLargeError(n int64) error {
+	return fmt.Errorf("sling: request body exceeds %d bytes", n)
+}
+
+// limitedBody is a request Body which returns an error once more than limit
+// bytes are read.
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	// read up to one byte past the limit to detect bodies which are too large
+	if remaining := b.limit - b.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		return 0, bodyTooLargeError(b.limit)
+	}
+	return n, err
+}
+
+// checkURL returns an error if reqURL violates the Sling's url requirements
+// (see RequireAbsoluteURL and RequireHTTPS).
+func (s *Sling) checkURL(reqURL *url.URL) error {
+	if (s.requireAbsoluteURL || s.requireHTTPS) && (!reqURL.IsAbs() || reqURL.Host == "") {
+		return fmt.Errorf("sling: url %q is not absolute", reqURL.String())
+	}
+	if s.requireHTTPS && reqURL.Scheme != "https" {
+		return fmt.Errorf("sling: url %q is not https", reqURL.String())
+	}
+	return nil
+}
+
+// withDefaultScheme prefixes rawURL with the given scheme if rawURL names a
+// host but no scheme. Relative paths and urls with a scheme are returned
+// unmodified.
+func withDefaultScheme(rawURL, scheme string) string {
+	if scheme == "" || rawURL == "" || strings.Contains(rawURL, "://") {
+		return rawURL
+	}
+	if strings.HasPrefix(rawURL, "//") {
+		return scheme + ":" + rawURL
+	}
+	if !namesHostWithoutScheme(rawURL) {
+		return rawURL
+	}
+	return scheme + "://" + rawURL
+}
+
+// isHostAndPort returns true if rawURL starts with a host and port rather
+// than a scheme, e.g. "api.io:8443/v1/".
+func isHostAndPort(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Opaque == "" {
+		return false
+	}
+	return '0' <= parsed.Opaque[0] && parsed.Opaque[0] <= '9'
+}
+
+// namesHostWithoutScheme returns true if rawURL starts with a host rather
+// than a scheme or a path, e.g. "api.io:8443/v1/".
+func namesHostWithoutScheme(rawURL string) bool {
+	return rawURL != "" && !strings.Contains(rawURL, "://") && !strings.HasPrefix(rawURL, "/")
+}
+
+// addQueryStructs parses url tagged query structs using the encoder (or
+// go-querystring if nil) to encode them to url.Values and format them onto
+// the url.RawQuery. Any query parsing or encoding errors are returned.
+func addQueryStructs(reqURL *url.URL, queryStructs []interface{}, encoder ValuesEncoder) error {
+	if encoder == nil {
+		encoder = goquery.Values
+	}
 	urlValues, err := url.ParseQuery(reqURL.RawQuery)
 	if err != nil {
 		return err
 	}
 	// encodes query structs into a url.Values map and merges maps
 	for _, queryStruct := range queryStructs {
-		queryValues, err := goquery.Values(queryStruct)
+		queryValues, err := encoder(queryStruct)
 		if err != nil {
 			return err
 		}
@@ -305,6 +686,17 @@ func addQueryStructs(reqURL *url.URL, queryStructs []interface{}) error {
 	return nil
 }
 
+// addRawQueries appends the pre-encoded rawQueries to the url.RawQuery,
+// separated by "&".
+func addRawQueries(reqURL *url.URL, rawQueries []string) {
+	for _, rawQuery := range rawQueries {
+		if reqURL.RawQuery != "" {
+			reqURL.RawQuery += "&"
+		}
+		reqURL.RawQuery += rawQuery
+	}
+}
+
 // getRequestBody returns the io.Reader which should be used as the body
 // of new Requests.
 func (s *Sling) getRequestBody() (body io.Reader, err error) {
@@ -314,7 +706,7 @@ func (s *Sling) getRequestBody() (body io.Reader, err error) {
 			return nil, err
 		}
 	} else if s.bodyForm != nil && s.header.Get(contentType) == formContentType {
-		body, err = encodeBodyForm(s.bodyForm)
+		body, err = encodeBodyForm(s.bodyForm, s.valuesEncoder)
 		if err != nil {
 			return nil, err
 		}
@@ -324,9 +716,69 @@ func (s *Sling) getRequestBody() (body io.Reader, err error) {
 	return body, nil
 }
 
+// hasBodyProvider returns true if the request body is computed by a
+// BodyProvider.
+func (s *Sling) hasBodyProvider() bool {
+	var body interface{}
+	if s.bodyJSON != nil && s.header.Get(contentType) == jsonContentType {
+		body = s.bodyJSON
+	} else if s.bodyForm != nil && s.header.Get(contentType) == formContentType {
+		body = s.bodyForm
+	}
+	_, ok := body.(BodyProvider)
+	return ok
+}
+
+// bodyProviderKey is the Request context key of a func which computes a
+// fresh body with the Sling's BodyProvider (see copyRequest).
+type bodyProviderKey struct{}
+
+// bodyProviderGetBody returns a GetBody-like func which computes a fresh
+// body with the Sling's BodyProvider on each call. Bodies over
+// MaxRequestBytes are refused.
+func (s *Sling) bodyProviderGetBody() func() (io.ReadCloser, error) {
+	// copy the Sling so later changes don't affect the request
+	bodySling := s.New()
+	return func() (io.ReadCloser, error) {
+		body, err := bodySling.getRequestBody()
+		if err != nil {
+			return nil, err
+		}
+		provided := &providedBody{Reader: body, length: -1}
+		if lengther, ok := body.(interface{ Len() int }); ok {
+			provided.length = lengther.Len()
+		}
+		if limit := bodySling.maxRequestBytes; limit > 0 && int64(provided.length) > limit {
+			return nil, bodyTooLargeError(limit)
+		}
+		return provided, nil
+	}
+}
+
+// providedBody is a request Body computed by a BodyProvider, which reports
+// its length so copies of a request (see copyRequest) can update their
+// ContentLength.
+type providedBody struct {
+	io.Reader
+	length int
+}
+
+func (b *providedBody) Close() error {
+	return nil
+}
+
+// Len returns the length of the Body, or -1 if it is unknown.
+func (b *providedBody) Len() int {
+	return b.length
+}
+
 // encodeBodyJSON JSON encodes the value pointed to by bodyJSON into an
 // io.Reader, typically for use as a Request Body.
 func encodeBodyJSON(bodyJSON interface{}, indent bool) (io.Reader, error) {
+	bodyJSON, err := provideBody(bodyJSON)
+	if err != nil {
+		return nil, err
+	}
 	var buf = new(bytes.Buffer)
 	if bodyJSON != nil {
 		buf = &bytes.Buffer{}
@@ -344,15 +796,32 @@ func encodeBodyJSON(bodyJSON interface{}, indent bool) (io.Reader, error) {
 }
 
 // encodeBodyForm url encodes the value pointed to by bodyForm into an
-// io.Reader, typically for use as a Request Body.
-func encodeBodyForm(bodyForm interface{}) (io.Reader, error) {
-	values, err := goquery.Values(bodyForm)
+// io.Reader, typically for use as a Request Body. The encoder (or
+// go-querystring if nil) converts bodyForm to url.Values.
+func encodeBodyForm(bodyForm interface{}, encoder ValuesEncoder) (io.Reader, error) {
+	if encoder == nil {
+		encoder = goquery.Values
+	}
+	bodyForm, err := provideBody(bodyForm)
+	if err != nil {
+		return nil, err
+	}
+	values, err := encoder(bodyForm)
 	if err != nil {
 		return nil, err
 	}
 	return strings.NewReader(values.Encode()), nil
 }
 
+// provideBody returns the value returned by body if it is a BodyProvider or
+// body itself otherwise.
+func provideBody(body interface{}) (interface{}, error) {
+	if provider, ok := body.(BodyProvider); ok {
+		return provider()
+	}
+	return body, nil
+}
+
 // addHeaders adds the key, value pairs from the given http.Header to the
 // request. Values for existing keys are appended to the keys values.
 func addHeaders(req *http.Request, header http.Header) {
@@ -363,19 +832,104 @@ func addHeaders(req *http.Request, header http.Header) {
 	}
 }
 
+// addDefaultHeaders adds the key, value pairs from the given http.Header to
+// the request for keys the request Header doesn't have.
+func addDefaultHeaders(req *http.Request, header http.Header) {
+	for key, values := range header {
+		if _, ok := req.Header[key]; !ok {
+			req.Header[key] = append([]string{}, values...)
+		}
+	}
+}
+
+// cloneHeader returns a copy of the given http.Header which shares no value
+// slices with it, or nil if header is nil.
+func cloneHeader(header http.Header) http.Header {
+	if header == nil {
+		return nil
+	}
+	headerCopy := make(http.Header, len(header))
+	for key, values := range header {
+		headerCopy[key] = append([]string{}, values...)
+	}
+	return headerCopy
+}
+
 // Sending
 
+// SniffJSON sets whether Do decodes responses which have no Content-Type
+// header as JSON when their body starts with '{' or '['. By default, such
+// responses are not decoded.
+func (s *Sling) SniffJSON(b bool) *Sling {
+	s.sniffJSON = b
+	return s
+}
+
+// StripPrefix sets a prefix which Do removes from the start of response
+// bodies, when present, before decoding them. Some APIs prepend an
+// anti-XSSI prefix such as ")]}',\n" to JSON responses which would
+// otherwise fail to decode.
+func (s *Sling) StripPrefix(prefix string) *Sling {
+	s.stripPrefix = prefix
+	return s
+}
+
+// BodyReadTimeout limits the time Do spends reading (and decoding) a
+// response Body once the response headers have arrived to d, after which Do
+// returns ErrBodyReadTimeout. This protects against servers which send
+// headers promptly but dribble the body, when the http Client has no overall
+// Timeout. A timeout of 0 means no limit.
+func (s *Sling) BodyReadTimeout(d time.Duration) *Sling {
+	s.bodyReadTimeout = d
+	return s
+}
+
+// AfterUnmarshal appends a func which Do calls with each value it decodes a
+// response into (successV or failureV) and the response, e.g. to validate
+// required fields. Funcs are called in the order they were added and the
+// first error returned is returned by Do, so invalid payloads surface as
+// errors rather than reaching callers.
+func (s *Sling) AfterUnmarshal(fn func(v interface{}, resp *http.Response) error) *Sling {
+	if fn != nil {
+		s.afterUnmarshal = append(s.afterUnmarshal, fn)
+	}
+	return s
+}
+
+// AutoDrain sets whether Do reads up to 64KB of any unread response Body
+// before closing it, so the connection can be reused even when a response
+// isn't decoded to its end. Draining waits for the server to send the rest
+// of the Body, so avoid it with streaming responses unless BodyReadTimeout
+// is also set. By default, Do closes response bodies without draining them.
+func (s *Sling) AutoDrain(b bool) *Sling {
+	s.autoDrain = b
+	return s
+}
+
+// IsSuccess sets the func Do uses to decide whether a response is a success,
+// to be decoded into successV, or a failure, to be decoded into failureV. By
+// default, 2XX responses are successes. For example, an API may return 202
+// Accepted with a job status which should be decoded differently from its
+// 200 OK resource. This only affects decoding; Doers which retry responses
+// classify them independently.
+func (s *Sling) IsSuccess(isSuccess func(resp *http.Response) bool) *Sling {
+	s.isSuccess = isSuccess
+	return s
+}
+
 // ReceiveSuccess creates a new HTTP request and returns the response. Success
-// responses (2XX) are JSON decoded into the value pointed to by successV.
-// Any error creating the request, sending it, or decoding a 2XX response
+// responses (2XX, unless redefined with IsSuccess) are JSON decoded into the
+// value pointed to by successV.
+// Any error creating the request, sending it, or decoding a success response
 // is returned.
 func (s *Sling) ReceiveSuccess(successV interface{}) (*http.Response, error) {
 	return s.Receive(successV, nil)
 }
 
 // Receive creates a new HTTP request and returns the response. Success
-// responses (2XX) are JSON decoded into the value pointed to by successV and
-// other responses are JSON decoded into the value pointed to by failureV.
+// responses (2XX, unless redefined with IsSuccess) are JSON decoded into the
+// value pointed to by successV and other responses are JSON decoded into the
+// value pointed to by failureV.
 // Any error creating the request, sending it, or decoding the response is
 // returned.
 // Receive is shorthand for calling Request and Do.
@@ -389,42 +943,208 @@ func (s *Sling) Receive(successV, failureV interface{}) (*http.Response, error)
 
 // Do sends an HTTP request and returns the response. Success responses (2XX)
 // are JSON decoded into the value pointed to by successV and other responses
-// are JSON decoded into the value pointed to by failureV.
+// are JSON decoded into the value pointed to by failureV. Success can be
+// redefined with IsSuccess.
 // Any error sending the request or decoding the response is returned.
+// If the Doer returns a response along with an error, both are returned.
+// The response Body is closed before Do returns, after being drained if
+// AutoDrain is set.
 func (s *Sling) Do(req *http.Request, successV, failureV interface{}) (*http.Response, error) {
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.doer().Do(req)
 	if err != nil {
+		// some Doers return a response along with an error, pass it along
+		// for inspection but release its Body
+		s.closeResponse(resp)
 		return resp, err
 	}
+	if s.bodyReadTimeout > 0 {
+		body := newTimeoutBody(resp.Body, s.bodyReadTimeout)
+		// deferred first so the timeout also bounds draining the Body below
+		defer body.stop()
+		resp.Body = body
+	}
 	// when err is nil, resp contains a non-nil resp.Body which must be closed
-	defer resp.Body.Close()
-	if strings.Contains(resp.Header.Get(contentType), jsonContentType) {
-		err = decodeResponseJSON(resp, successV, failureV)
+	defer s.closeResponse(resp)
+	if s.stripPrefix != "" {
+		stripResponsePrefix(resp, s.stripPrefix)
+	}
+	if isJSONContentType(resp.Header.Get(contentType)) || s.sniffJSON && sniffResponseJSON(resp) {
+		err = decodeResponseJSON(resp, successV, failureV, s.isSuccess)
+		if err == nil {
+			err = s.applyAfterUnmarshal(responseValue(resp, successV, failureV, s.isSuccess), resp)
+		}
 	}
 	return resp, err
 }
 
-// decodeResponse decodes response Body into the value pointed to by successV
-// if the response is a success (2XX) or into the value pointed to by failureV
-// otherwise. If the successV or failureV argument to decode into is nil,
-// decoding is skipped.
-// Caller is responsible for closing the resp.Body.
-func decodeResponseJSON(resp *http.Response, successV, failureV interface{}) error {
-	if code := resp.StatusCode; 200 <= code && code <= 299 {
-		if successV != nil {
-			return decodeResponseBodyJSON(resp, successV)
+// closeResponse closes the response Body, draining it first if AutoDrain is
+// set. It is safe to call with a nil response or Body.
+func (s *Sling) closeResponse(resp *http.Response) {
+	if s.autoDrain {
+		DrainAndClose(resp)
+	} else if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
+}
+
+// applyAfterUnmarshal calls the AfterUnmarshal funcs with the decoded value
+// v, returning the first error. Nothing is called if v is nil.
+func (s *Sling) applyAfterUnmarshal(v interface{}, resp *http.Response) error {
+	if v == nil {
+		return nil
+	}
+	for _, fn := range s.afterUnmarshal {
+		if err := fn(v, resp); err != nil {
+			return err
 		}
-	} else {
-		if failureV != nil {
-			return decodeResponseBodyJSON(resp, failureV)
+	}
+	return nil
+}
+
+// stripResponsePrefix removes prefix from the start of the response Body if
+// the Body starts with it. The resp.Body is replaced with a buffered reader
+// so the peeked bytes are not lost when the prefix doesn't match.
+func stripResponsePrefix(resp *http.Response, prefix string) {
+	body := bufio.NewReaderSize(resp.Body, len(prefix))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{body, resp.Body}
+	if peeked, _ := body.Peek(len(prefix)); string(peeked) == prefix {
+		body.Discard(len(prefix))
+	}
+}
+
+// sniffResponseJSON returns true if the response has no Content-Type and its
+// Body starts with '{' or '[' (after any whitespace). The resp.Body is
+// replaced with a buffered reader so the sniffed bytes are not lost.
+func sniffResponseJSON(resp *http.Response) bool {
+	if resp.Header.Get(contentType) != "" {
+		return false
+	}
+	body := bufio.NewReader(resp.Body)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{body, resp.Body}
+	for {
+		c, err := body.ReadByte()
+		if err != nil {
+			return false
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{', '[':
+			body.UnreadByte()
+			return true
+		default:
+			body.UnreadByte()
+			return false
+		}
+	}
+}
+
+// doer returns the Doer used to do requests, which is a copy of the http
+// Client which doesn't follow redirects if NoRedirects is set.
+func (s *Sling) doer() Doer {
+	if client, ok := s.httpClient.(*http.Client); ok && s.noRedirects {
+		noRedirectClient := *client
+		noRedirectClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
 		}
+		return &noRedirectClient
+	}
+	return s.httpClient
+}
+
+// timeoutBody is a response Body which is closed once its timeout expires,
+// after which reads return ErrBodyReadTimeout.
+type timeoutBody struct {
+	io.ReadCloser
+	timer    *time.Timer
+	timedOut int32
+}
+
+// newTimeoutBody returns a timeoutBody which times out after d.
+func newTimeoutBody(body io.ReadCloser, d time.Duration) *timeoutBody {
+	b := &timeoutBody{ReadCloser: body}
+	b.timer = time.AfterFunc(d, func() {
+		atomic.StoreInt32(&b.timedOut, 1)
+		body.Close()
+	})
+	return b
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if atomic.LoadInt32(&b.timedOut) == 1 {
+		return n, ErrBodyReadTimeout
+	}
+	return n, err
+}
+
+// stop stops the timeout.
+func (b *timeoutBody) stop() {
+	b.timer.Stop()
+}
+
+// isJSONContentType returns true if the Content-Type value is
+// "application/json" or has a "+json" suffix (e.g. "application/hal+json"),
+// ignoring parameters such as charset.
+func isJSONContentType(value string) bool {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return false
+	}
+	return mediaType == jsonContentType ||
+		(strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
+
+// decodeResponse decodes response Body into the value pointed to by successV
+// if the response is a success (see responseValue) or into the value pointed
+// to by failureV otherwise. If the successV or failureV argument to decode
+// into is nil, decoding is skipped.
+// Caller is responsible for closing the resp.Body.
+func decodeResponseJSON(resp *http.Response, successV, failureV interface{}, isSuccess func(resp *http.Response) bool) error {
+	if v := responseValue(resp, successV, failureV, isSuccess); v != nil {
+		return decodeResponseBodyJSON(resp, v)
 	}
 	return nil
 }
 
+// responseValue returns successV if the response is a success according to
+// isSuccess, or is 2XX if isSuccess is nil, and failureV otherwise.
+func responseValue(resp *http.Response, successV, failureV interface{}, isSuccess func(resp *http.Response) bool) interface{} {
+	if isSuccess != nil {
+		if isSuccess(resp) {
+			return successV
+		}
+		return failureV
+	}
+	if code := resp.StatusCode; 200 <= code && code <= 299 {
+		return successV
+	}
+	return failureV
+}
+
 // decodeResponseBodyJSON JSON decodes a Response Body into the value pointed
 // to by v.
 // Caller must provide a non-nil v and close the resp.Body.
 func decodeResponseBodyJSON(resp *http.Response, v interface{}) error {
 	return json.NewDecoder(resp.Body).Decode(v)
 }
+
+// DrainAndClose reads any remaining response Body and closes it, so the
+// underlying connection can be reused by the http.Client. Bodies with more
+// than 64KB remaining are closed without being fully read. It is safe to call
+// with a nil response or Body.
+// Callers using Request() with their own client should DrainAndClose
+// responses they are done with, even when returning early.
+func DrainAndClose(resp *http.Response) error {
+	if resp == nil || resp.Body == nil {
+		return nil
+	}
+	io.CopyN(ioutil.Discard, resp.Body, maxDrainBytes)
+	return resp.Body.Close()
+}
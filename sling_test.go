@@ -2,6 +2,7 @@ package sling
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,7 +14,9 @@ import (
 	"net/url"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type FakeParams struct {
@@ -62,6 +65,8 @@ func TestSlingNew(t *testing.T) {
 		&Sling{bodyJSON: FakeModel{Text: "a"}},
 		&Sling{bodyJSON: nil},
 		&Sling{indentJSON: true},
+		&Sling{defaultScheme: "https"},
+		New().RequireAbsoluteURL().RequireHTTPS(),
 		New().Add("Content-Type", "application/json"),
 		New().Add("A", "B").Add("a", "c").New(),
 		New().Add("A", "B").New().Add("a", "c"),
@@ -111,9 +116,54 @@ func TestSlingNew(t *testing.T) {
 		if child.indentJSON != sling.indentJSON {
 			t.Errorf("indentJSON was not copied. expected: %v, got %v", sling.indentJSON, child.indentJSON)
 		}
+		// url requirements should be copied
+		if child.requireAbsoluteURL != sling.requireAbsoluteURL || child.requireHTTPS != sling.requireHTTPS {
+			t.Errorf("url requirements were not copied. expected: %v %v, got %v %v", sling.requireAbsoluteURL, sling.requireHTTPS, child.requireAbsoluteURL, child.requireHTTPS)
+		}
+		// defaultScheme should be copied
+		if child.defaultScheme != sling.defaultScheme {
+			t.Errorf("defaultScheme was not copied. expected: %v, got %v", sling.defaultScheme, child.defaultScheme)
+		}
+	}
+}
+
+func TestSlingNew_headerValuesNotShared(t *testing.T) {
+	// grow the parent's header value slice so it has spare capacity
+	parent := New().Add("A", "1").Add("A", "2").Add("A", "3")
+	childX := parent.New().Add("A", "x")
+	childY := parent.New().Add("A", "y")
+	if values := childX.header["A"]; !reflect.DeepEqual(values, []string{"1", "2", "3", "x"}) {
+		t.Errorf("sibling Slings share header values, expected %v, got %v", []string{"1", "2", "3", "x"}, values)
+	}
+	if values := childY.header["A"]; !reflect.DeepEqual(values, []string{"1", "2", "3", "y"}) {
+		t.Errorf("expected %v, got %v", []string{"1", "2", "3", "y"}, values)
+	}
+	if values := parent.header["A"]; !reflect.DeepEqual(values, []string{"1", "2", "3"}) {
+		t.Errorf("expected %v, got %v", []string{"1", "2", "3"}, values)
 	}
 }
 
+func TestSlingNew_concurrent(t *testing.T) {
+	parent := New().Base("http://a.io/").Add("A", "1").Add("A", "2").Add("A", "3").QueryStruct(paramsA)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value := fmt.Sprint(i)
+			req, err := parent.New().Add("A", value).Path(value).Request()
+			if err != nil {
+				t.Errorf("expected nil, got %v", err)
+				return
+			}
+			if values := req.Header["A"]; !reflect.DeepEqual(values, []string{"1", "2", "3", value}) {
+				t.Errorf("expected %v, got %v", []string{"1", "2", "3", value}, values)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
 func TestClientSetter(t *testing.T) {
 	developerClient := &http.Client{}
 	cases := []struct {
@@ -179,6 +229,13 @@ func TestPathSetter(t *testing.T) {
 		{"http://a.io/", "http://b.io/", "http://b.io/"},
 		{"http://a.io", "http://b.io", "http://b.io"},
 		{"http://a.io/", "http://b.io", "http://b.io"},
+		// relative bases are resolved as paths
+		{"a/b", "/c", "/c"},
+		{"foo", "bar", "/bar"},
+		{"foo/bar/", "../baz", "/foo/baz"},
+		// a host and port without a scheme is kept
+		{"a.io:8443/v1/", "foo", "a.io:8443/v1/foo"},
+		{"a.io:8443/v1/", "/foo", "a.io:8443/foo"},
 		// empty base, empty path
 		{"", "http://b.io", "http://b.io"},
 		{"http://a.io", "", "http://a.io"},
@@ -192,6 +249,56 @@ func TestPathSetter(t *testing.T) {
 	}
 }
 
+func TestDefaultScheme(t *testing.T) {
+	cases := []struct {
+		sling       *Sling
+		expectedURL string
+	}{
+		{New().DefaultScheme("https").Base("api.io:8443/v1/"), "https://api.io:8443/v1/"},
+		{New().DefaultScheme("https").Base("api.io:8443/v1/").Path("foo"), "https://api.io:8443/v1/foo"},
+		{New().Base("api.io/v1/").DefaultScheme("https").Path("foo"), "https://api.io/v1/foo"},
+		// DefaultScheme may be set after Path
+		{New().Base("api.io:8443/v1/").Path("foo").DefaultScheme("https"), "https://api.io:8443/v1/foo"},
+		{New().Base("api.io:8443/v1/").Path("/foo").DefaultScheme("https"), "https://api.io:8443/foo"},
+		{New().DefaultScheme("https").Base("//api.io/"), "https://api.io/"},
+		// explicit schemes are kept
+		{New().DefaultScheme("https").Base("http://api.io/"), "http://api.io/"},
+		// copied to child Slings
+		{New().DefaultScheme("https").New().Base("api.io"), "https://api.io"},
+	}
+	for _, c := range cases {
+		req, err := c.sling.Request()
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+			continue
+		}
+		if req.URL.String() != c.expectedURL {
+			t.Errorf("expected url %s, got %s", c.expectedURL, req.URL.String())
+		}
+	}
+}
+
+func TestWithDefaultScheme(t *testing.T) {
+	cases := []struct {
+		rawURL   string
+		scheme   string
+		expected string
+	}{
+		{"api.io", "https", "https://api.io"},
+		{"api.io:8443/v1", "https", "https://api.io:8443/v1"},
+		{"//api.io/v1", "https", "https://api.io/v1"},
+		{"http://api.io", "https", "http://api.io"},
+		{"/v1/foo", "https", "/v1/foo"},
+		{"", "https", ""},
+		{"api.io", "", "api.io"},
+	}
+	for _, c := range cases {
+		if actual := withDefaultScheme(c.rawURL, c.scheme); actual != c.expected {
+			t.Errorf("expected %s, got %s", c.expected, actual)
+		}
+	}
+}
+
 func TestMethodSetters(t *testing.T) {
 	cases := []struct {
 		sling          *Sling
@@ -256,6 +363,115 @@ func TestSetHeader(t *testing.T) {
 	}
 }
 
+type traceKey struct{}
+
+func TestHeaderTemplate(t *testing.T) {
+	fromContext := func(req *http.Request) (interface{}, error) {
+		return map[string]interface{}{"TraceID": req.Context().Value(traceKey{}), "Method": req.Method}, nil
+	}
+	sling := New().Post("http://a.io/").HeaderTemplate("X-Trace", "trace={{.TraceID}} method={{.Method}}", fromContext)
+	req, err := sling.Request()
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if value := req.Header.Get("X-Trace"); value != "trace=<no value> method=POST" {
+		t.Errorf("expected X-Trace %s, got %s", "trace=<no value> method=POST", value)
+	}
+
+	counter := 0
+	sling = New().HeaderTemplate("X-Count", "{{.}}", func(req *http.Request) (interface{}, error) {
+		counter++
+		return counter, nil
+	})
+	// rendered for each new request
+	for _, expected := range []string{"1", "2"} {
+		req, _ := sling.Request()
+		if value := req.Header.Get("X-Count"); value != expected {
+			t.Errorf("expected X-Count %s, got %s", expected, value)
+		}
+	}
+	// nil data renders without a value
+	req, _ = New().HeaderTemplate("X-Static", "static", nil).Request()
+	if value := req.Header.Get("X-Static"); value != "static" {
+		t.Errorf("expected X-Static %s, got %s", "static", value)
+	}
+}
+
+func TestHeaderTemplate_errors(t *testing.T) {
+	dataErr := errors.New("data failure")
+	cases := []struct {
+		sling       *Sling
+		expectedErr string
+	}{
+		{New().HeaderTemplate("X-A", "{{.Foo", nil), "template: X-A:1: unclosed action"},
+		{New().HeaderTemplate("X-A", "{{.Foo}}", func(*http.Request) (interface{}, error) { return 5, nil }), "template: X-A:1:2: executing \"X-A\" at <.Foo>: can't evaluate field Foo in type int"},
+		{New().HeaderTemplate("X-A", "{{.}}", func(*http.Request) (interface{}, error) { return nil, dataErr }), "data failure"},
+	}
+	for _, c := range cases {
+		req, err := c.sling.Request()
+		if err == nil || err.Error() != c.expectedErr {
+			t.Errorf("expected error %v, got %v", c.expectedErr, err)
+		}
+		if req != nil {
+			t.Errorf("expected nil Request, got %+v", req)
+		}
+	}
+}
+
+func TestDefaultHeaders(t *testing.T) {
+	base := New().DefaultHeaders(http.Header{"user-agent": []string{"sling"}, "Accept": []string{"application/json"}})
+	cases := []struct {
+		sling          *Sling
+		expectedHeader map[string][]string
+	}{
+		{base.New(), map[string][]string{"User-Agent": []string{"sling"}, "Accept": []string{"application/json"}}},
+		// Add and Set take priority over defaults
+		{base.New().Set("Accept", "text/xml"), map[string][]string{"User-Agent": []string{"sling"}, "Accept": []string{"text/xml"}}},
+		{base.New().Add("accept", "text/xml"), map[string][]string{"User-Agent": []string{"sling"}, "Accept": []string{"text/xml"}}},
+		// defaults replace existing defaults
+		{base.New().DefaultHeaders(http.Header{"Accept": []string{"text/xml"}}), map[string][]string{"User-Agent": []string{"sling"}, "Accept": []string{"text/xml"}}},
+		// removal of inherited defaults
+		{base.New().RemoveDefaultHeader("accept"), map[string][]string{"User-Agent": []string{"sling"}}},
+		{New().RemoveDefaultHeader("Accept"), map[string][]string{}},
+	}
+	for _, c := range cases {
+		req, _ := c.sling.Request()
+		headerMap := map[string][]string(req.Header)
+		if !reflect.DeepEqual(c.expectedHeader, headerMap) {
+			t.Errorf("not DeepEqual: expected %v, got %v", c.expectedHeader, headerMap)
+		}
+	}
+	// children should not mutate the parent's defaults
+	req, _ := base.Request()
+	if value := req.Header.Get("Accept"); value != "application/json" {
+		t.Errorf("parent default headers were mutated, got Accept %s", value)
+	}
+}
+
+func TestNoCacheHeaders(t *testing.T) {
+	req, _ := New().NoCacheHeaders().Request()
+	expected := map[string][]string{"Cache-Control": []string{"no-cache"}, "Pragma": []string{"no-cache"}}
+	if headerMap := map[string][]string(req.Header); !reflect.DeepEqual(expected, headerMap) {
+		t.Errorf("not DeepEqual: expected %v, got %v", expected, headerMap)
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	count := 0
+	newKey := func() string {
+		count++
+		return fmt.Sprintf("key-%d", count)
+	}
+	sling := New().Post("http://a.io/").IdempotencyKey(newKey)
+	// each new request gets a new key
+	for _, expected := range []string{"key-1", "key-2"} {
+		req, _ := sling.Request()
+		if value := req.Header.Get("Idempotency-Key"); value != expected {
+			t.Errorf("expected Idempotency-Key %s, got %s", expected, value)
+		}
+	}
+}
+
 func TestBasicAuth(t *testing.T) {
 	cases := []struct {
 		sling        *Sling
@@ -480,6 +696,38 @@ func TestRequest_urlAndMethod(t *testing.T) {
 	}
 }
 
+func TestRequest_urlRequirements(t *testing.T) {
+	cases := []struct {
+		sling       *Sling
+		expectedErr string
+	}{
+		{New().Base("http://a.io/").RequireAbsoluteURL(), ""},
+		{New().Base("https://a.io/").RequireHTTPS(), ""},
+		{New().DefaultScheme("https").Base("a.io").RequireHTTPS(), ""},
+		{New().Base("foo/bar").RequireAbsoluteURL(), `sling: url "foo/bar" is not absolute`},
+		{New().Base("/foo").RequireHTTPS(), `sling: url "/foo" is not absolute`},
+		{New().Base("http://a.io/").RequireHTTPS(), `sling: url "http://a.io/" is not https`},
+		{New().Base("http://a.io/").RequireHTTPS().New().Path("foo"), `sling: url "http://a.io/foo" is not https`},
+		// relative urls are allowed by default
+		{New().Path("foo/bar"), ""},
+	}
+	for _, c := range cases {
+		req, err := c.sling.Request()
+		if c.expectedErr == "" {
+			if err != nil {
+				t.Errorf("expected nil, got %v", err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != c.expectedErr {
+			t.Errorf("expected error %v, got %v", c.expectedErr, err)
+		}
+		if req != nil {
+			t.Errorf("expected nil Request, got %+v", req)
+		}
+	}
+}
+
 func TestRequest_queryStructs(t *testing.T) {
 	cases := []struct {
 		sling       *Sling
@@ -542,6 +790,297 @@ func TestRequest_body(t *testing.T) {
 	}
 }
 
+func TestRequest_getBody(t *testing.T) {
+	cases := []struct {
+		sling        *Sling
+		expectedBody string
+	}{
+		{New().BodyJSON(modelA), "{\"text\":\"note\",\"favorite_count\":12}\n"},
+		{New().BodyJSON(modelA).IndentJSON(true), "{\n  \"text\": \"note\",\n  \"favorite_count\": 12\n}\n"},
+		{New().BodyForm(paramsB), "count=25&kind_name=recent"},
+	}
+	for _, c := range cases {
+		req, _ := c.sling.Request()
+		if req.GetBody == nil {
+			t.Errorf("expected GetBody to be set for %q", c.expectedBody)
+			continue
+		}
+		// GetBody should replay the body, even after it has been read
+		for i := 0; i < 2; i++ {
+			body, err := req.GetBody()
+			if err != nil {
+				t.Errorf("expected nil, got %v", err)
+				continue
+			}
+			buf := new(bytes.Buffer)
+			buf.ReadFrom(body)
+			if value := buf.String(); value != c.expectedBody {
+				t.Errorf("expected GetBody %q, got %q", c.expectedBody, value)
+			}
+		}
+	}
+}
+
+func TestRequest_contentLength(t *testing.T) {
+	cases := []struct {
+		sling                 *Sling
+		expectedContentLength int64
+	}{
+		// determined by http.NewRequest
+		{New().Body(strings.NewReader("abc")), 3},
+		{New().Body(strings.NewReader("abc")).ContentLength(ContentLengthUnknown), -1},
+		{New().Body(strings.NewReader("abc")).ContentLength(0), 0},
+		{New().BodyForm(paramsA).ContentLength(ContentLengthUnknown).New(), -1},
+		{New().ContentLength(5).ContentLength(7), 7},
+	}
+	for _, c := range cases {
+		req, _ := c.sling.Request()
+		if req.ContentLength != c.expectedContentLength {
+			t.Errorf("expected ContentLength %d, got %d", c.expectedContentLength, req.ContentLength)
+		}
+	}
+}
+
+func TestRequest_bodyProvider(t *testing.T) {
+	count := 0
+	provider := BodyProvider(func() (interface{}, error) {
+		count++
+		return FakeParams{KindName: "recent", Count: count}, nil
+	})
+	cases := []struct {
+		sling          *Sling
+		expectedBodies []string
+	}{
+		{New().BodyJSON(provider), []string{"{\"KindName\":\"recent\",\"Count\":1}\n", "{\"KindName\":\"recent\",\"Count\":2}\n"}},
+		{New().BodyForm(provider), []string{"count=3&kind_name=recent", "count=4&kind_name=recent"}},
+	}
+	for _, c := range cases {
+		// the provider should be called for each new request
+		for _, expectedBody := range c.expectedBodies {
+			req, err := c.sling.Request()
+			if err != nil {
+				t.Errorf("expected nil, got %v", err)
+				continue
+			}
+			buf := new(bytes.Buffer)
+			buf.ReadFrom(req.Body)
+			if value := buf.String(); value != expectedBody {
+				t.Errorf("expected Request.Body %s, got %s", expectedBody, value)
+			}
+		}
+	}
+}
+
+func TestRequest_bodyProviderGetBody(t *testing.T) {
+	count := 8
+	provider := BodyProvider(func() (interface{}, error) {
+		count++
+		return FakeParams{KindName: "recent", Count: count}, nil
+	})
+	cases := []struct {
+		sling          *Sling
+		expectedBodies []string
+	}{
+		{New().BodyJSON(provider), []string{"{\"KindName\":\"recent\",\"Count\":9}\n", "{\"KindName\":\"recent\",\"Count\":10}\n", "{\"KindName\":\"recent\",\"Count\":11}\n"}},
+		{New().BodyForm(provider), []string{"count=12&kind_name=recent", "count=13&kind_name=recent", "count=14&kind_name=recent"}},
+	}
+	readBody := func(body io.Reader) string {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(body)
+		return buf.String()
+	}
+	for _, c := range cases {
+		req, err := c.sling.Request()
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+		// GetBody replays the encoded body, as net/http expects
+		for i := 0; i < 2; i++ {
+			body, err := req.GetBody()
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if value := readBody(body); value != c.expectedBodies[0] {
+				t.Errorf("expected GetBody %s, got %s", c.expectedBodies[0], value)
+			}
+		}
+		// copies made by retrying Doers call the provider again
+		for _, expectedBody := range c.expectedBodies[1:] {
+			reqCopy, err := copyRequest(req)
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if value := readBody(reqCopy.Body); value != expectedBody {
+				t.Errorf("expected copied Body %s, got %s", expectedBody, value)
+			}
+		}
+	}
+}
+
+func TestRequest_bodyProviderRetries(t *testing.T) {
+	count := 8
+	provider := BodyProvider(func() (interface{}, error) {
+		count++
+		return FakeParams{KindName: "recent", Count: count}, nil
+	})
+	next := &stubDoer{statuses: []int{503, 200}, header: http.Header{"Retry-After": []string{"0"}}}
+	sling := New().Doer(RetryAfterDoer(next, 1, nil)).Post("http://example.com/").BodyForm(provider)
+	if _, err := sling.Receive(nil, nil); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	// the retry sends a fresh body, with its own ContentLength
+	expectedBodies := []string{"count=9&kind_name=recent", "count=10&kind_name=recent"}
+	if !reflect.DeepEqual(expectedBodies, next.bodies) {
+		t.Errorf("expected bodies %v, got %v", expectedBodies, next.bodies)
+	}
+	if length := next.requests[1].ContentLength; length != int64(len(expectedBodies[1])) {
+		t.Errorf("expected ContentLength %d, got %d", len(expectedBodies[1]), length)
+	}
+}
+
+func TestRequest_bodyProviderMaxRequestBytes(t *testing.T) {
+	body := "short"
+	provider := BodyProvider(func() (interface{}, error) {
+		return body, nil
+	})
+	req, err := New().MaxRequestBytes(8).BodyJSON(provider).Request()
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	body = "much longer"
+	if _, err := copyRequest(req); err == nil || err.Error() != "sling: request body exceeds 8 bytes" {
+		t.Errorf("expected body size error, got %v", err)
+	}
+}
+
+func TestRequest_bodyProviderError(t *testing.T) {
+	expectedErr := errors.New("provider failure")
+	provider := BodyProvider(func() (interface{}, error) {
+		return nil, expectedErr
+	})
+	slings := []*Sling{
+		New().BodyJSON(provider),
+		New().BodyForm(provider),
+	}
+	for _, sling := range slings {
+		req, err := sling.Request()
+		if err != expectedErr {
+			t.Errorf("expected %v, got %v", expectedErr, err)
+		}
+		if req != nil {
+			t.Errorf("expected nil Request, got %+v", req)
+		}
+	}
+}
+
+func TestRequest_maxRequestBytes(t *testing.T) {
+	// unknown length body, as it is not a bytes or strings reader
+	unknownLength := func(body string) io.Reader {
+		return struct{ io.Reader }{strings.NewReader(body)}
+	}
+	cases := []struct {
+		sling          *Sling
+		expectedReqErr string
+		expectedErr    string
+		expectedBody   string
+	}{
+		{New().MaxRequestBytes(3).Body(strings.NewReader("abc")), "", "", "abc"},
+		{New().MaxRequestBytes(3).Body(unknownLength("abc")), "", "", "abc"},
+		{New().MaxRequestBytes(0).Body(strings.NewReader("abcd")), "", "", "abcd"},
+		{New().MaxRequestBytes(3).New().BodyForm(paramsA), "sling: request body exceeds 3 bytes", "", ""},
+		{New().MaxRequestBytes(3).Body(strings.NewReader("abcd")), "sling: request body exceeds 3 bytes", "", ""},
+		{New().MaxRequestBytes(3).Body(unknownLength("abcd")), "", "sling: request body exceeds 3 bytes", ""},
+		{New().MaxRequestBytes(3).Body(strings.NewReader("abcd")).ContentLength(ContentLengthUnknown), "", "sling: request body exceeds 3 bytes", ""},
+	}
+	for _, c := range cases {
+		req, err := c.sling.Request()
+		if c.expectedReqErr != "" {
+			if err == nil || err.Error() != c.expectedReqErr {
+				t.Errorf("expected error %v, got %v", c.expectedReqErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+			continue
+		}
+		bodies := []io.Reader{req.Body}
+		if req.GetBody != nil {
+			body, _ := req.GetBody()
+			bodies = append(bodies, body)
+		}
+		for _, body := range bodies {
+			value, err := ioutil.ReadAll(body)
+			if c.expectedErr != "" {
+				if err == nil || err.Error() != c.expectedErr {
+					t.Errorf("expected error %v, got %v", c.expectedErr, err)
+				}
+				continue
+			}
+			if err != nil || string(value) != c.expectedBody {
+				t.Errorf("expected body %s, got %s, %v", c.expectedBody, value, err)
+			}
+		}
+	}
+}
+
+func TestRequest_maxRequestBytesNoBody(t *testing.T) {
+	req, err := New().Post("http://a.io/").MaxRequestBytes(3).Body(http.NoBody).Request()
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	// NoBody is left as is, so Transports and Doers can recognize it
+	if req.Body != http.NoBody || req.ContentLength != 0 {
+		t.Errorf("expected NoBody with ContentLength 0, got %v, %d", req.Body, req.ContentLength)
+	}
+}
+
+type contextKey string
+
+func TestRequest_context(t *testing.T) {
+	ctx := context.WithValue(context.Background(), contextKey("tenant"), "acme")
+	cases := []struct {
+		sling    *Sling
+		expected interface{}
+	}{
+		{New(), nil},
+		{New().Context(ctx), "acme"},
+		{New().Context(ctx).New().Get("http://example.com"), "acme"},
+		{New().Context(ctx).Context(nil), nil},
+	}
+	for _, c := range cases {
+		var interceptorValue interface{}
+		req, err := c.sling.Interceptor(func(req *http.Request) error {
+			interceptorValue = req.Context().Value(contextKey("tenant"))
+			return nil
+		}).Request()
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+		}
+		if value := req.Context().Value(contextKey("tenant")); value != c.expected {
+			t.Errorf("expected %v, got %v", c.expected, value)
+		}
+		if interceptorValue != c.expected {
+			t.Errorf("expected interceptors to see %v, got %v", c.expected, interceptorValue)
+		}
+	}
+}
+
+func TestReceive_contextCanceled(t *testing.T) {
+	client, mux, server := testServer()
+	defer server.Close()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"text": "Some text"}`)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := New().Client(client).Context(ctx).Get("http://example.com/").ReceiveSuccess(new(FakeModel))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
 func TestRequest_bodyNoData(t *testing.T) {
 	// test that Body is left nil when no bodyJSON or bodyStruct set
 	slings := []*Sling{
@@ -610,6 +1149,126 @@ func TestRequest_headers(t *testing.T) {
 	}
 }
 
+func TestRequest_interceptors(t *testing.T) {
+	setQuery := func(req *http.Request) error {
+		req.URL.RawQuery = "b=2&a=1"
+		return nil
+	}
+	addHeader := func(req *http.Request) error {
+		req.Header.Add("X-Query", req.URL.RawQuery)
+		return nil
+	}
+	parent := New().Base("http://a.io/").QueryStruct(paramsA).Interceptor(setQuery)
+	child := parent.New().Interceptor(addHeader)
+
+	req, err := child.Request()
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	// interceptors apply in order, after the query is encoded
+	if req.URL.String() != "http://a.io/?b=2&a=1" {
+		t.Errorf("expected url %s, got %s", "http://a.io/?b=2&a=1", req.URL.String())
+	}
+	if value := req.Header.Get("X-Query"); value != "b=2&a=1" {
+		t.Errorf("expected X-Query %s, got %s", "b=2&a=1", value)
+	}
+	// adding to the child should not mutate the parent
+	req, _ = parent.Request()
+	if value := req.Header.Get("X-Query"); value != "" {
+		t.Errorf("parent interceptors were mutated, got X-Query %s", value)
+	}
+}
+
+func TestRequest_interceptorError(t *testing.T) {
+	expectedErr := errors.New("interceptor failure")
+	req, err := New().Interceptor(func(req *http.Request) error {
+		return expectedErr
+	}).Request()
+	if err != expectedErr {
+		t.Errorf("expected %v, got %v", expectedErr, err)
+	}
+	if req != nil {
+		t.Errorf("expected nil Request, got %+v", req)
+	}
+}
+
+// Status is a type go-querystring can't encode as desired
+type Status int
+
+func (s Status) String() string {
+	return [...]string{"open", "closed"}[s]
+}
+
+func TestQueryEncoder(t *testing.T) {
+	encoder := func(v interface{}) (url.Values, error) {
+		if status, ok := v.(Status); ok {
+			return url.Values{"status": []string{status.String()}}, nil
+		}
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+	cases := []struct {
+		sling        *Sling
+		expectedURL  string
+		expectedBody string
+		expectedErr  string
+	}{
+		{New().Base("http://a.io").QueryEncoder(encoder).QueryStruct(Status(1)), "http://a.io?status=closed", "", ""},
+		{New().Base("http://a.io").QueryEncoder(encoder).New().BodyForm(Status(0)), "http://a.io", "status=open", ""},
+		{New().Base("http://a.io").QueryEncoder(encoder).QueryStruct(paramsB), "", "", "unsupported type sling.FakeParams"},
+		// nil restores go-querystring
+		{New().Base("http://a.io").QueryEncoder(encoder).QueryEncoder(nil).QueryStruct(paramsA), "http://a.io?limit=30", "", ""},
+	}
+	for _, c := range cases {
+		req, err := c.sling.Request()
+		if c.expectedErr != "" {
+			if err == nil || err.Error() != c.expectedErr {
+				t.Errorf("expected error %v, got %v", c.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+			continue
+		}
+		if req.URL.String() != c.expectedURL {
+			t.Errorf("expected url %s, got %s", c.expectedURL, req.URL.String())
+		}
+		if c.expectedBody != "" {
+			buf := new(bytes.Buffer)
+			buf.ReadFrom(req.Body)
+			if value := buf.String(); value != c.expectedBody {
+				t.Errorf("expected Request.Body %s, got %s", c.expectedBody, value)
+			}
+		}
+	}
+}
+
+func TestRequest_rawQuery(t *testing.T) {
+	cases := []struct {
+		sling       *Sling
+		expectedURL string
+	}{
+		{New().Base("http://a.io").RawQuery("a=1;b=2"), "http://a.io?a=1;b=2"},
+		{New().Base("http://a.io").RawQuery("q=a%2fb+c").RawQuery("flag"), "http://a.io?q=a%2fb+c&flag"},
+		// appended after query structs
+		{New().Base("http://a.io?x=1").QueryStruct(paramsA).RawQuery("z=a,b"), "http://a.io?limit=30&x=1&z=a,b"},
+		{New().Base("http://a.io").RawQuery("a=1").New().RawQuery(""), "http://a.io?a=1"},
+	}
+	for _, c := range cases {
+		req, _ := c.sling.Request()
+		if req.URL.String() != c.expectedURL {
+			t.Errorf("expected url %s, got %s", c.expectedURL, req.URL.String())
+		}
+	}
+	// adding to a child should not mutate the parent
+	parent := New().Base("http://a.io").RawQuery("a=1")
+	parent.New().RawQuery("b=2")
+	req, _ := parent.Request()
+	if req.URL.RawQuery != "a=1" {
+		t.Errorf("expected %s, got %s", "a=1", req.URL.RawQuery)
+	}
+}
+
 func TestAddQueryStructs(t *testing.T) {
 	cases := []struct {
 		rawurl       string
@@ -625,7 +1284,7 @@ func TestAddQueryStructs(t *testing.T) {
 	}
 	for _, c := range cases {
 		reqURL, _ := url.Parse(c.rawurl)
-		addQueryStructs(reqURL, c.queryStructs)
+		addQueryStructs(reqURL, c.queryStructs, nil)
 		if reqURL.String() != c.expected {
 			t.Errorf("expected %s, got %s", c.expected, reqURL.String())
 		}
@@ -757,6 +1416,47 @@ func TestDo_onFailureWithNilValue(t *testing.T) {
 	}
 }
 
+func TestDo_isSuccess(t *testing.T) {
+	only200 := func(resp *http.Response) bool { return resp.StatusCode == 200 }
+	cases := []struct {
+		sling            *Sling
+		status           int
+		body             string
+		expectedModel    *FakeModel
+		expectedAPIError *APIError
+	}{
+		{New().IsSuccess(only200), 200, `{"text": "Some text"}`, &FakeModel{Text: "Some text"}, &APIError{}},
+		{New().IsSuccess(only200).New(), 200, `{"text": "Some text"}`, &FakeModel{Text: "Some text"}, &APIError{}},
+		{New().IsSuccess(only200), 202, `{"message": "Pending", "code": 1}`, &FakeModel{}, &APIError{Message: "Pending", Code: 1}},
+		// 4XX responses can be treated as successes
+		{New().IsSuccess(func(resp *http.Response) bool { return resp.StatusCode < 500 }), 404, `{"text": "Some text"}`, &FakeModel{Text: "Some text"}, &APIError{}},
+		// a nil func restores the default of 2XX
+		{New().IsSuccess(only200).IsSuccess(nil), 202, `{"text": "Some text"}`, &FakeModel{Text: "Some text"}, &APIError{}},
+	}
+	for _, c := range cases {
+		client, mux, server := testServer()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(c.status)
+			io.WriteString(w, c.body)
+		})
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		model := new(FakeModel)
+		apiError := new(APIError)
+		_, err := c.sling.Client(client).Do(req, model, apiError)
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+		}
+		if !reflect.DeepEqual(c.expectedModel, model) {
+			t.Errorf("expected %v, got %v", c.expectedModel, model)
+		}
+		if !reflect.DeepEqual(c.expectedAPIError, apiError) {
+			t.Errorf("expected %v, got %v", c.expectedAPIError, apiError)
+		}
+		server.Close()
+	}
+}
+
 func TestDo_skipDecodingIfContentTypeWrong(t *testing.T) {
 	client, mux, server := testServer()
 	defer server.Close()
@@ -777,6 +1477,494 @@ func TestDo_skipDecodingIfContentTypeWrong(t *testing.T) {
 	}
 }
 
+// trackingBody is a response Body which records how it was consumed.
+type trackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+// doerFunc adapts a function to the Doer interface.
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// stubDoer is a configurable Doer for tests. It records the requests it
+// receives, along with their bodies read into strings. Each request is
+// answered by respond, if set, or else with the next of statuses (repeating
+// the last, default 200) and a copy of header.
+type stubDoer struct {
+	statuses []int
+	header   http.Header
+	respond  func(attempt int, req *http.Request) (*http.Response, error)
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	// closed when the next request is recorded
+	recorded chan struct{}
+}
+
+func (r *stubDoer) Do(req *http.Request) (*http.Response, error) {
+	attempt := r.record(req)
+	if r.respond != nil {
+		return r.respond(attempt, req)
+	}
+	status := http.StatusOK
+	if n := len(r.statuses); n > 0 {
+		if attempt >= n {
+			attempt = n - 1
+		}
+		status = r.statuses[attempt]
+	}
+	return testResponse(status, cloneHeader(r.header)), nil
+}
+
+// record reads and records req, returning its index among the recorded
+// requests.
+func (r *stubDoer) record(req *http.Request) int {
+	body := ""
+	if req.Body != nil {
+		b, _ := ioutil.ReadAll(req.Body)
+		body = string(b)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	if r.recorded != nil {
+		close(r.recorded)
+		r.recorded = nil
+	}
+	return len(r.requests) - 1
+}
+
+// wait blocks until n requests have been recorded, or returns the ctx error
+// if it is done first.
+func (r *stubDoer) wait(ctx context.Context, n int) error {
+	for {
+		r.mu.Lock()
+		if len(r.requests) >= n {
+			r.mu.Unlock()
+			return nil
+		}
+		if r.recorded == nil {
+			r.recorded = make(chan struct{})
+		}
+		recorded := r.recorded
+		r.mu.Unlock()
+		select {
+		case <-recorded:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// count returns the number of recorded requests.
+func (r *stubDoer) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+// headers returns the key header value of each recorded request.
+func (r *stubDoer) headers(key string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	values := make([]string, len(r.requests))
+	for i, req := range r.requests {
+		values[i] = req.Header.Get(key)
+	}
+	return values
+}
+
+// testResponse returns a response with the given status and header and an
+// empty Body.
+func testResponse(status int, header http.Header) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{StatusCode: status, Header: header, Body: ioutil.NopCloser(strings.NewReader(""))}
+}
+
+func TestDo_drainsAndClosesBody(t *testing.T) {
+	cases := []struct {
+		sling         *Sling
+		contentType   string
+		expectDrained bool
+	}{
+		// bodies are closed without draining by default
+		{New(), "application/json", false},
+		{New().AutoDrain(true), "application/json", true},
+		{New().AutoDrain(true).New(), "application/json", true},
+		// bodies which aren't decoded should be drained too
+		{New().AutoDrain(true), "text/html", true},
+	}
+	for _, c := range cases {
+		// trailing data beyond what the JSON decoder buffers
+		body := &trackingBody{Reader: strings.NewReader(`{"text": "Some text"}` + strings.Repeat(" ", 4096))}
+		doer := doerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: 200, Header: http.Header{"Content-Type": []string{c.contentType}}, Body: body}, nil
+		})
+		req, _ := http.NewRequest("GET", "http://example.com/success", nil)
+		c.sling.Doer(doer).Do(req, new(FakeModel), nil)
+		if !body.closed {
+			t.Errorf("expected response Body to be closed")
+		}
+		if n, _ := body.Read(make([]byte, 1)); (n == 0) != c.expectDrained {
+			t.Errorf("expected response Body drained %v, got %v", c.expectDrained, n == 0)
+		}
+	}
+}
+
+func TestDo_streamingResponse(t *testing.T) {
+	client, mux, server := testServer()
+	defer server.Close()
+	done := make(chan struct{})
+	defer close(done)
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+		// keep the stream open
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	start := time.Now()
+	resp, err := New().Client(client).Get("http://example.com/events").Receive(nil, nil)
+	if err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+	if resp == nil || resp.StatusCode != 200 {
+		t.Errorf("expected 200 response, got %v", resp)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Do to return without reading the stream, took %v", elapsed)
+	}
+}
+
+func TestDo_doerErrorWithResponse(t *testing.T) {
+	body := &trackingBody{Reader: strings.NewReader(`{"message": "partial"}`)}
+	expectedErr := errors.New("doer failure")
+	doer := doerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 502, Header: http.Header{"Content-Type": []string{"application/json"}}, Body: body}, expectedErr
+	})
+	apiError := new(APIError)
+	resp, err := New().Doer(doer).Get("http://example.com/").Receive(nil, apiError)
+	if err != expectedErr {
+		t.Errorf("expected %v, got %v", expectedErr, err)
+	}
+	if resp == nil || resp.StatusCode != 502 {
+		t.Fatalf("expected response with status 502, got %v", resp)
+	}
+	if !body.closed {
+		t.Errorf("expected response Body to be closed")
+	}
+	// responses accompanying an error are not decoded
+	if apiError.Message != "" {
+		t.Errorf("failureV should not be populated, got %v", apiError)
+	}
+}
+
+func TestDrainAndClose(t *testing.T) {
+	if err := DrainAndClose(nil); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+	if err := DrainAndClose(&http.Response{}); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+	body := &trackingBody{Reader: strings.NewReader(strings.Repeat("a", maxDrainBytes+1))}
+	DrainAndClose(&http.Response{Body: body})
+	if !body.closed {
+		t.Errorf("expected response Body to be closed")
+	}
+	// reading is limited to maxDrainBytes
+	if n, _ := body.Read(make([]byte, 2)); n != 1 {
+		t.Errorf("expected 1 unread byte, got %d", n)
+	}
+}
+
+func TestIsJSONContentType(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected bool
+	}{
+		{"application/json", true},
+		{"application/json; charset=utf-8", true},
+		{"Application/JSON", true},
+		{"application/hal+json", true},
+		{"application/problem+json; charset=utf-8", true},
+		{"application/jsonp", false},
+		{"text/xml+json", false},
+		{"text/html", false},
+		{"", false},
+		{"application/json;;", false},
+	}
+	for _, c := range cases {
+		if actual := isJSONContentType(c.value); actual != c.expected {
+			t.Errorf("expected %v, got %v for %q", c.expected, actual, c.value)
+		}
+	}
+}
+
+func TestDo_sniffJSON(t *testing.T) {
+	cases := []struct {
+		sling         *Sling
+		contentType   string
+		body          string
+		expectedModel *FakeModel
+	}{
+		// decoding is skipped without a Content-Type by default
+		{New(), "", `{"text": "Some text"}`, &FakeModel{}},
+		{New().SniffJSON(true), "", `{"text": "Some text"}`, &FakeModel{Text: "Some text"}},
+		{New().SniffJSON(true), "", " \n\t{\"text\": \"Some text\"}", &FakeModel{Text: "Some text"}},
+		{New().SniffJSON(true).New(), "", `{"text": "Some text"}`, &FakeModel{Text: "Some text"}},
+		// bodies which don't look like JSON are not decoded
+		{New().SniffJSON(true), "", `<text>Some text</text>`, &FakeModel{}},
+		{New().SniffJSON(true), "", ``, &FakeModel{}},
+		// an explicit Content-Type is respected
+		{New().SniffJSON(true), "text/html", `{"text": "Some text"}`, &FakeModel{}},
+	}
+	for _, c := range cases {
+		client, mux, server := testServer()
+		mux.HandleFunc("/success", func(w http.ResponseWriter, r *http.Request) {
+			// prevent net/http from detecting a Content-Type
+			w.Header()["Content-Type"] = nil
+			if c.contentType != "" {
+				w.Header().Set("Content-Type", c.contentType)
+			}
+			io.WriteString(w, c.body)
+		})
+		req, _ := http.NewRequest("GET", "http://example.com/success", nil)
+		model := new(FakeModel)
+		_, err := c.sling.Client(client).Do(req, model, nil)
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+		}
+		if !reflect.DeepEqual(c.expectedModel, model) {
+			t.Errorf("expected %v, got %v for body %q", c.expectedModel, model, c.body)
+		}
+		server.Close()
+	}
+}
+
+func TestDo_stripPrefix(t *testing.T) {
+	cases := []struct {
+		sling         *Sling
+		body          string
+		expectedModel *FakeModel
+	}{
+		{New().StripPrefix(")]}',\n"), ")]}',\n{\"text\": \"Some text\"}", &FakeModel{Text: "Some text"}},
+		{New().StripPrefix(")]}',\n").New(), ")]}',\n{\"text\": \"Some text\"}", &FakeModel{Text: "Some text"}},
+		{New().StripPrefix("while(1);"), `while(1);{"text": "Some text"}`, &FakeModel{Text: "Some text"}},
+		// bodies without the prefix are decoded as-is
+		{New().StripPrefix(")]}',\n"), `{"text": "Some text"}`, &FakeModel{Text: "Some text"}},
+		{New().StripPrefix("while(1);"), `{}`, &FakeModel{}},
+	}
+	for _, c := range cases {
+		client, mux, server := testServer()
+		mux.HandleFunc("/success", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, c.body)
+		})
+		req, _ := http.NewRequest("GET", "http://example.com/success", nil)
+		model := new(FakeModel)
+		_, err := c.sling.Client(client).Do(req, model, nil)
+		if err != nil {
+			t.Errorf("expected nil, got %v for body %q", err, c.body)
+		}
+		if !reflect.DeepEqual(c.expectedModel, model) {
+			t.Errorf("expected %v, got %v for body %q", c.expectedModel, model, c.body)
+		}
+		server.Close()
+	}
+}
+
+func TestDo_noRedirects(t *testing.T) {
+	client, mux, server := testServer()
+	defer server.Close()
+	mux.HandleFunc("/presign", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/upload", http.StatusFound)
+	})
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"text": "uploaded"}`)
+	})
+	cases := []struct {
+		sling          *Sling
+		expectedStatus int
+		expectedModel  *FakeModel
+	}{
+		{New().Client(client), 200, &FakeModel{Text: "uploaded"}},
+		{New().Client(client).NoRedirects(true), 302, &FakeModel{}},
+		{New().Client(client).NoRedirects(true).New(), 302, &FakeModel{}},
+		{New().Client(client).NoRedirects(true).NoRedirects(false), 200, &FakeModel{Text: "uploaded"}},
+	}
+	for _, c := range cases {
+		model := new(FakeModel)
+		resp, err := c.sling.Get("http://example.com/presign").Receive(model, nil)
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+			continue
+		}
+		if resp.StatusCode != c.expectedStatus {
+			t.Errorf("expected %d, got %d", c.expectedStatus, resp.StatusCode)
+		}
+		if !reflect.DeepEqual(c.expectedModel, model) {
+			t.Errorf("expected %v, got %v", c.expectedModel, model)
+		}
+	}
+	// the shared client should still follow redirects
+	if client.CheckRedirect != nil {
+		t.Errorf("expected shared client CheckRedirect to be unchanged")
+	}
+}
+
+func TestDo_bodyReadTimeout(t *testing.T) {
+	client, mux, server := testServer()
+	defer server.Close()
+	done := make(chan struct{})
+	defer close(done)
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"text": `)
+		w.(http.Flusher).Flush()
+		// dribble the rest of the body
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+		fmt.Fprintf(w, `"Some text"}`)
+	})
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"text": "Some text"}`)
+	})
+
+	start := time.Now()
+	resp, err := New().Client(client).BodyReadTimeout(20*time.Millisecond).Get("http://example.com/slow").Receive(new(FakeModel), nil)
+	if err != ErrBodyReadTimeout {
+		t.Errorf("expected %v, got %v", ErrBodyReadTimeout, err)
+	}
+	if resp == nil || resp.StatusCode != 200 {
+		t.Errorf("expected 200 response, got %v", resp)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected body read to time out, took %v", elapsed)
+	}
+
+	model := new(FakeModel)
+	_, err = New().Client(client).BodyReadTimeout(time.Second).New().Get("http://example.com/fast").Receive(model, nil)
+	if err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+	if model.Text != "Some text" {
+		t.Errorf("expected %s, got %s", "Some text", model.Text)
+	}
+}
+
+func TestDo_bodyReadTimeoutWhileDraining(t *testing.T) {
+	client, mux, server := testServer()
+	defer server.Close()
+	done := make(chan struct{})
+	defer close(done)
+	trickle := func(w http.ResponseWriter, r *http.Request) {
+		for {
+			select {
+			case <-done:
+				return
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			if _, err := fmt.Fprintf(w, " "); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	}
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"text": "Some text"}`)
+		w.(http.Flusher).Flush()
+		trickle(w, r)
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "Some text")
+		w.(http.Flusher).Flush()
+		trickle(w, r)
+	})
+
+	for _, path := range []string{"/json", "/text"} {
+		start := time.Now()
+		model := new(FakeModel)
+		_, err := New().Client(client).AutoDrain(true).BodyReadTimeout(100*time.Millisecond).Get("http://example.com"+path).Receive(model, nil)
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected draining %s to time out, took %v", path, elapsed)
+		}
+	}
+}
+
+func TestDo_afterUnmarshal(t *testing.T) {
+	client, mux, server := testServer()
+	defer server.Close()
+	mux.HandleFunc("/success", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"favorite_count": 24}`)
+	})
+	mux.HandleFunc("/failure", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		fmt.Fprintf(w, `{"message": "Invalid argument"}`)
+	})
+	requireText := func(v interface{}, resp *http.Response) error {
+		if model, ok := v.(*FakeModel); ok && model.Text == "" {
+			return fmt.Errorf("missing text in %d response", resp.StatusCode)
+		}
+		return nil
+	}
+	var calls []interface{}
+	record := func(v interface{}, resp *http.Response) error {
+		calls = append(calls, v)
+		return nil
+	}
+	base := New().Client(client).Base("http://example.com/").AfterUnmarshal(record).AfterUnmarshal(requireText)
+
+	model, apiError := new(FakeModel), new(APIError)
+	_, err := base.New().Get("success").Receive(model, apiError)
+	if err == nil || err.Error() != "missing text in 200 response" {
+		t.Errorf("expected error %v, got %v", "missing text in 200 response", err)
+	}
+	_, err = base.New().Get("failure").Receive(model, apiError)
+	if err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+	// funcs are not called when decoding is skipped
+	_, err = base.New().Get("failure").Receive(model, nil)
+	if err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+	expectedCalls := []interface{}{model, apiError}
+	if !reflect.DeepEqual(expectedCalls, calls) {
+		t.Errorf("expected calls with %v, got %v", expectedCalls, calls)
+	}
+}
+
 func TestReceive_success(t *testing.T) {
 	client, mux, server := testServer()
 	defer server.Close()
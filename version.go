@@ -0,0 +1,33 @@
+package sling
+
+// VersionStyle is a strategy for sending an API version with requests.
+type VersionStyle int
+
+const (
+	// VersionHeader sends the version in the X-API-Version header.
+	VersionHeader VersionStyle = iota
+	// VersionMediaType sends the version as a vendor media type in the
+	// Accept header, e.g. "github.v3" is sent as
+	// "application/vnd.github.v3+json".
+	VersionMediaType
+	// VersionPath extends the rawURL with the version as a path segment,
+	// e.g. "v2" turns "https://api.io/" into "https://api.io/v2/".
+	VersionPath
+)
+
+// APIVersion sets the API version requests are made for, using the given
+// style, so a version bump is a one line change. Header and media type
+// versions replace any previously set X-API-Version or Accept header. Path
+// versions are resolved like Path, so the Sling's Base should have a
+// trailing slash and APIVersion should be called before paths relative to
+// the version are added.
+func (s *Sling) APIVersion(v string, style VersionStyle) *Sling {
+	switch style {
+	case VersionMediaType:
+		return s.Set("Accept", "application/vnd."+v+"+json")
+	case VersionPath:
+		return s.Path(v + "/")
+	default:
+		return s.Set("X-API-Version", v)
+	}
+}
@@ -0,0 +1,34 @@
+package sling
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestAPIVersion(t *testing.T) {
+	cases := []struct {
+		sling          *Sling
+		expectedURL    string
+		expectedHeader http.Header
+	}{
+		{New().Base("https://api.io/").APIVersion("2", VersionHeader).Path("users"), "https://api.io/users", http.Header{"X-Api-Version": []string{"2"}}},
+		{New().Base("https://api.io/").APIVersion("github.v3", VersionMediaType).Path("users"), "https://api.io/users", http.Header{"Accept": []string{"application/vnd.github.v3+json"}}},
+		{New().Base("https://api.io/").APIVersion("v2", VersionPath).Path("users"), "https://api.io/v2/users", http.Header{}},
+		// later versions replace earlier ones
+		{New().APIVersion("1", VersionHeader).APIVersion("2", VersionHeader), "", http.Header{"X-Api-Version": []string{"2"}}},
+		{New().Set("Accept", "text/plain").APIVersion("foo.v2", VersionMediaType), "", http.Header{"Accept": []string{"application/vnd.foo.v2+json"}}},
+	}
+	for _, c := range cases {
+		req, err := c.sling.Request()
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+		}
+		if req.URL.String() != c.expectedURL {
+			t.Errorf("expected %v, got %v", c.expectedURL, req.URL.String())
+		}
+		if !reflect.DeepEqual(c.expectedHeader, req.Header) {
+			t.Errorf("expected %v, got %v", c.expectedHeader, req.Header)
+		}
+	}
+}